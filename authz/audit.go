@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AuditEvent represents authentication audit event
+type AuditEvent struct {
+	Timestamp int64  `json:"timestamp" bson:"timestamp"` // unix time of the event
+	Success   bool   `json:"success" bson:"success"`     // outcome of authentication
+	Subject   string `json:"subject" bson:"subject"`     // token subject or user name
+	Provider  string `json:"provider" bson:"provider"`   // provider which validated the token
+	Reason    string `json:"reason" bson:"reason"`       // failure reason
+	ClientIP  string `json:"client_ip" bson:"client_ip"` // client IP address
+	Route     string `json:"route" bson:"route"`         // HTTP route of the request
+	Method    string `json:"method" bson:"method"`       // HTTP method of the request
+}
+
+// String provides string representation of audit event
+func (e AuditEvent) String() string {
+	status := "success"
+	if !e.Success {
+		status = "failure"
+	}
+	return fmt.Sprintf("audit %s subject=%q provider=%q ip=%q route=%q %s reason=%q",
+		status, e.Subject, e.Provider, e.ClientIP, e.Route, e.Method, e.Reason)
+}
+
+// AuditFilter defines filter to look-up audit events
+type AuditFilter struct {
+	Subject  string    // match given subject
+	ClientIP string    // match given client IP
+	Failures bool      // only select failed events
+	Since    time.Time // only select events after given time
+	Limit    int       // maximum number of events to return, 0 means no limit
+}
+
+// match checks if given event satisfies the filter
+func (f AuditFilter) match(e AuditEvent) bool {
+	if f.Subject != "" && e.Subject != f.Subject {
+		return false
+	}
+	if f.ClientIP != "" && e.ClientIP != f.ClientIP {
+		return false
+	}
+	if f.Failures && e.Success {
+		return false
+	}
+	if !f.Since.IsZero() && e.Timestamp < f.Since.Unix() {
+		return false
+	}
+	return true
+}
+
+// AuditSink defines interface to store and query audit events
+type AuditSink interface {
+	Emit(event AuditEvent) error
+	Query(filter AuditFilter) ([]AuditEvent, error)
+}
+
+// Audit holds audit sink used by authentication functions,
+// if it is not set audit events are written to the log
+var Audit AuditSink
+
+// AuditSuccess enables audit of successful authentications, by default
+// only failures are audited
+var AuditSuccess bool
+
+// AuditLog emits given audit event to the configured audit sink
+func AuditLog(event AuditEvent) {
+	if event.Success && !AuditSuccess {
+		return
+	}
+	if event.Timestamp == 0 {
+		event.Timestamp = time.Now().Unix()
+	}
+	if Audit == nil {
+		log.Println(event.String())
+		return
+	}
+	if err := Audit.Emit(event); err != nil {
+		log.Printf("unable to emit %s, error %v", event.String(), err)
+	}
+}
+
+// RecentFailures returns audit failures of given user since given time
+func RecentFailures(subject string, since time.Time, limit int) ([]AuditEvent, error) {
+	filter := AuditFilter{Subject: subject, Failures: true, Since: since, Limit: limit}
+	return queryAudit(filter)
+}
+
+// RecentFailuresByIP returns audit failures of given client IP since given time
+func RecentFailuresByIP(ip string, since time.Time, limit int) ([]AuditEvent, error) {
+	filter := AuditFilter{ClientIP: ip, Failures: true, Since: since, Limit: limit}
+	return queryAudit(filter)
+}
+
+// helper function to query configured audit sink
+func queryAudit(filter AuditFilter) ([]AuditEvent, error) {
+	if Audit == nil {
+		return nil, errors.New("audit sink is not configured")
+	}
+	return Audit.Query(filter)
+}
+
+// helper function to order events by time (most recent first) and apply limit
+func recentEvents(events []AuditEvent, limit int) []AuditEvent {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp > events[j].Timestamp
+	})
+	if limit > 0 && len(events) > limit {
+		events = events[:limit]
+	}
+	return events
+}
+
+// FileAuditSink stores audit events in JSON-lines file
+type FileAuditSink struct {
+	File  string // name of the file
+	mutex sync.Mutex
+}
+
+// NewFileAuditSink returns audit sink for given file name
+func NewFileAuditSink(fname string) *FileAuditSink {
+	return &FileAuditSink{File: fname}
+}
+
+// Emit implements AuditSink interface
+func (s *FileAuditSink) Emit(event AuditEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	file, err := os.OpenFile(s.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	data = append(data, '\n')
+	_, err = file.Write(data)
+	return err
+}
+
+// Query implements AuditSink interface
+func (s *FileAuditSink) Query(filter AuditFilter) ([]AuditEvent, error) {
+	var out []AuditEvent
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	file, err := os.Open(s.File)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return out, nil
+		}
+		return out, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			log.Printf("unable to parse audit record in %s, error %v", s.File, err)
+			continue
+		}
+		if filter.match(event) {
+			out = append(out, event)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return out, err
+	}
+	return recentEvents(out, filter.Limit), nil
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestFileAuditSink
+func TestFileAuditSink(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "audit.jsonl")
+	Audit = NewFileAuditSink(fname)
+	AuditSuccess = true
+	defer func() { Audit = nil; AuditSuccess = false }()
+
+	now := time.Now().Unix()
+	AuditLog(AuditEvent{Timestamp: now - 10, Subject: "alice", ClientIP: "1.1.1.1", Reason: "expired"})
+	AuditLog(AuditEvent{Timestamp: now - 5, Subject: "alice", ClientIP: "2.2.2.2", Reason: "bad signature"})
+	AuditLog(AuditEvent{Timestamp: now, Subject: "alice", ClientIP: "1.1.1.1", Success: true})
+	AuditLog(AuditEvent{Timestamp: now, Subject: "bob", ClientIP: "1.1.1.1", Reason: "expired"})
+
+	events, err := RecentFailures("alice", time.Unix(now-60, 0), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("wrong number of alice failures %+v", events)
+	}
+	if events[0].Reason != "bad signature" {
+		t.Errorf("events are not ordered by time %+v", events)
+	}
+
+	events, err = RecentFailuresByIP("1.1.1.1", time.Unix(now-60, 0), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Subject != "bob" {
+		t.Errorf("wrong failures for IP %+v", events)
+	}
+
+	// successful authentications are not audited by default
+	AuditSuccess = false
+	AuditLog(AuditEvent{Timestamp: now, Subject: "carol", Success: true})
+	events, err = Audit.Query(AuditFilter{Subject: "carol"})
+	if err != nil || len(events) != 0 {
+		t.Errorf("success is audited by default %+v error %v", events, err)
+	}
+}
+
+// TestInspectRequestProviders
+func TestInspectRequestProviders(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "audit.jsonl")
+	Audit = NewFileAuditSink(fname)
+	defer func() { Audit = nil }()
+	OAuthProviders = map[string]Provider{"https://example.com": {URL: "https://example.com"}}
+	defer func() { OAuthProviders = nil }()
+
+	r := httptest.NewRequest("GET", "/data", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("Authorization", "Bearer bad")
+	if _, err := InspectRequestProviders(r, []string{"https://example.com"}, 0); err == nil {
+		t.Fatal("invalid token is accepted")
+	}
+	events, err := RecentFailuresByIP("192.0.2.1", time.Now().Add(-time.Minute), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Route != "/data" || events[0].Method != "GET" {
+		t.Errorf("wrong failure event %+v", events)
+	}
+}
+
+// TestTokenMiddlewareAudit
+func TestTokenMiddlewareAudit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	fname := filepath.Join(t.TempDir(), "audit.jsonl")
+	Audit = NewFileAuditSink(fname)
+	defer func() { Audit = nil }()
+
+	// token signed with other key claims login of another user
+	token, err := UserToken(User{Login: "alice"}, "other", 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.GET("/data", TokenMiddleware("client", 0), func(c *gin.Context) {})
+	req := httptest.NewRequest("GET", "/data", nil)
+	req.RemoteAddr = "192.0.2.2:1234"
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("invalid token response %d", w.Code)
+	}
+	events, err := RecentFailuresByIP("192.0.2.2", time.Now().Add(-time.Minute), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Subject != "" {
+		t.Errorf("failure event records unverified subject %+v", events)
+	}
+}
@@ -4,12 +4,19 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"strings"
+	"sync"
 )
 
-// OAuthProviders contains maps of all participated providers
+// OAuthProviders contains maps of all participated providers, it is
+// replaced by UpdateProviders
 var OAuthProviders map[string]Provider
 
+// providersMutex guards OAuthProviders
+var providersMutex sync.RWMutex
+
 // TokenAttributes contains structure of access token attributes
 type TokenAttributes struct {
 	Subject      string `json:"sub"`           // token subject
@@ -44,9 +51,17 @@ func (t *TokenInfo) String() string {
 	return s
 }
 
-// Init initializes map of OAuth providers
+// Init initializes map of OAuth providers, it exits on errors
 func Init(providers []string, verbose int) {
-	OAuthProviders = make(map[string]Provider)
+	if err := UpdateProviders(providers, verbose); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// UpdateProviders initializes given OAuth providers and replaces current
+// ones, e.g. on configuration reload, current providers are kept on errors
+func UpdateProviders(providers []string, verbose int) error {
+	pmap := make(map[string]Provider)
 	for _, purl := range providers {
 		if verbose > 0 {
 			log.Println("initialize provider ", purl)
@@ -54,26 +69,66 @@ func Init(providers []string, verbose int) {
 		p := Provider{}
 		err := p.Init(purl, verbose)
 		if err != nil {
-			log.Fatalf("fail to initialize %s error %v", p.URL, err)
+			return fmt.Errorf("fail to initialize %s error %v", purl, err)
 		}
-		OAuthProviders[purl] = p
+		pmap[purl] = p
 	}
+	providersMutex.Lock()
+	OAuthProviders = pmap
+	providersMutex.Unlock()
+	return nil
+}
+
+// helper function to get OAuth provider of given URL
+func oauthProvider(purl string) (Provider, bool) {
+	providersMutex.RLock()
+	defer providersMutex.RUnlock()
+	p, ok := OAuthProviders[purl]
+	return p, ok
 }
 
 // InspectTokenProviders inspects token against all participated providers and return
 // TokenAttributes
 func InspectTokenProviders(token string, providers []string, verbose int) (TokenAttributes, error) {
+	return inspectProviders(token, providers, verbose, AuditEvent{})
+}
+
+// InspectRequestProviders inspects token of HTTP request against all participated
+// providers and return TokenAttributes, audit events contain request details
+func InspectRequestProviders(r *http.Request, providers []string, verbose int) (TokenAttributes, error) {
+	event := AuditEvent{
+		ClientIP: r.RemoteAddr,
+		Route:    r.URL.Path,
+		Method:   r.Method,
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		event.ClientIP = host
+	}
+	return inspectProviders(getToken(r), providers, verbose, event)
+}
+
+// helper function to inspect token against providers and audit the outcome
+func inspectProviders(token string, providers []string, verbose int, event AuditEvent) (TokenAttributes, error) {
 	for _, purl := range providers {
-		if p, ok := OAuthProviders[purl]; ok {
+		if p, ok := oauthProvider(purl); ok {
 			attrs, err := InspectToken(p, token, verbose)
 			if err == nil {
 				if verbose > 0 {
 					log.Println("token is validated with provider ", purl)
 				}
+				subject := attrs.UserName
+				if subject == "" {
+					subject = attrs.Subject
+				}
+				event.Success = true
+				event.Subject = subject
+				event.Provider = purl
+				AuditLog(event)
 				return attrs, nil
-			} else {
-				log.Println("provider", p.URL, " token error ", err)
 			}
+			event.Provider = purl
+			event.Reason = err.Error()
+			AuditLog(event)
 		}
 	}
 	msg := fmt.Sprintf("Token is not valid with participated providers: %v", providers)
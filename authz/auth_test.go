@@ -0,0 +1,21 @@
+package auth
+
+import "testing"
+
+// TestUpdateProviders
+func TestUpdateProviders(t *testing.T) {
+	OAuthProviders = map[string]Provider{"https://example.com": {URL: "https://example.com"}}
+	defer func() { OAuthProviders = nil }()
+	if err := UpdateProviders([]string{"http://127.0.0.1:1"}, 0); err == nil {
+		t.Fatal("unavailable provider is accepted")
+	}
+	if _, ok := oauthProvider("https://example.com"); !ok {
+		t.Error("current providers are not kept on errors")
+	}
+	if err := UpdateProviders(nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := oauthProvider("https://example.com"); ok {
+		t.Error("providers are not replaced")
+	}
+}
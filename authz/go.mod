@@ -1,11 +1,13 @@
 module github.com/OreCast/common/authz
 
-go 1.21.0
+go 1.21.3
 
 require (
+	github.com/OreCast/common/utils v0.0.0-20231008113920-e5b3f8d8b2d9
 	github.com/gin-gonic/gin v1.9.1
 	github.com/golang-jwt/jwt/v4 v4.5.1
 	github.com/pascaldekloe/jwt v1.12.0
+	golang.org/x/crypto v0.31.0
 )
 
 require (
@@ -27,7 +29,7 @@ require (
 	github.com/twitchyliquid64/golang-asm v0.15.1 // indirect
 	github.com/ugorji/go/codec v1.2.11 // indirect
 	golang.org/x/arch v0.3.0 // indirect
-	golang.org/x/crypto v0.31.0 // indirect
+	golang.org/x/exp v0.0.0-20230728194245-b0cb94b80691 // indirect
 	golang.org/x/net v0.21.0 // indirect
 	golang.org/x/sys v0.28.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
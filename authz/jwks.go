@@ -215,7 +215,7 @@ func tokenClaims(provider Provider, token string) (map[string]interface{}, error
 	out := make(map[string]interface{})
 	// First parse without checking signature, to get the Kid
 	claims, err := jwt.ParseWithoutCheck([]byte(token))
-	log.Println("ParseWithoutCheck returns %v", err)
+	log.Printf("ParseWithoutCheck returns %v", err)
 	if err != nil {
 		return out, err
 	}
@@ -21,13 +21,28 @@ func TokenMiddleware(clientId string, verbose int) gin.HandlerFunc {
 		// check if user request has valid token
 		tokenStr := getToken(c.Request)
 		token := &Token{AccessToken: tokenStr}
-		if err := token.Validate(clientId); err != nil {
-			msg := fmt.Sprintf("invalid token %s, error %v", tokenStr, err)
-			log.Println("WARNING:", msg)
+		event := AuditEvent{
+			Provider: "local",
+			ClientIP: c.ClientIP(),
+			Route:    c.FullPath(),
+			Method:   c.Request.Method,
+		}
+		// login of invalid token is not trusted and is not recorded
+		claims, err := token.accessClaims(clientId)
+		if err != nil {
+			event.Reason = err.Error()
+			AuditLog(event)
+			if verbose > 0 {
+				msg := fmt.Sprintf("invalid token %s, error %v", tokenStr, err)
+				log.Println("WARNING:", msg)
+			}
 			c.AbortWithStatusJSON(
 				http.StatusUnauthorized, gin.H{"status": "fail", "error": err.Error()})
 			return
 		}
+		event.Success = true
+		event.Subject = claims.Login
+		AuditLog(event)
 		if verbose > 0 {
 			log.Println("INFO: token is validated")
 		}
@@ -0,0 +1,392 @@
+package auth
+
+// OAuth2 token endpoint handlers, see RFC 6749
+// https://datatracker.ietf.org/doc/html/rfc6749
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/OreCast/common/utils"
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+// OAuth2 error codes, see RFC 6749 section 5.2
+const (
+	OAuthInvalidRequest       = "invalid_request"
+	OAuthInvalidClient        = "invalid_client"
+	OAuthInvalidGrant         = "invalid_grant"
+	OAuthUnauthorizedClient   = "unauthorized_client"
+	OAuthUnsupportedGrantType = "unsupported_grant_type"
+	OAuthInvalidScope         = "invalid_scope"
+	OAuthServerError          = "server_error"
+)
+
+// errors returned by client management functions
+var (
+	ErrClientNotFound = errors.New("client not found")
+	ErrClientExists   = errors.New("client already exists")
+)
+
+// errors of client authentication reported as invalid_client
+var (
+	errNoClientCredentials = errors.New("missing client credentials")
+	errClientSecret        = errors.New("invalid client secret")
+)
+
+// refreshAudience marks refresh tokens issued by OAuthServer
+const refreshAudience = "refresh"
+
+// DefaultAccessExpires defines expiration of access tokens in seconds
+// issued by OAuthServer without AccessExpires
+var DefaultAccessExpires int64 = 3600
+
+// DefaultRefreshExpires defines expiration of refresh tokens in seconds
+// issued by OAuthServer without RefreshExpires
+var DefaultRefreshExpires int64 = 24 * 3600
+
+// OAuthError represents RFC 6749 error response
+type OAuthError struct {
+	Error       string `json:"error"`                       // error code
+	Description string `json:"error_description,omitempty"` // human readable description
+}
+
+// Client represents registered OAuth client
+type Client struct {
+	ClientID   string   `json:"client_id"`   // client id
+	Secret     string   `json:"-"`           // hash of client secret
+	Name       string   `json:"name"`        // client name
+	GrantTypes []string `json:"grant_types"` // allowed grant types
+	Scopes     []string `json:"scopes"`      // allowed scopes
+	Created    int64    `json:"created"`     // creation time
+}
+
+// ClientStore defines interface to persist OAuth clients
+type ClientStore interface {
+	GetClient(clientID string) (Client, error)
+	AddClient(client Client) error
+	RemoveClient(clientID string) error
+}
+
+// RegisterClient creates new OAuth client and returns its secret,
+// only hash of the secret is kept in the store
+func RegisterClient(store ClientStore, name string, grantTypes, scopes []string) (Client, string, error) {
+	clientID, _, err := newToken()
+	if err != nil {
+		return Client{}, "", err
+	}
+	// client secrets are random tokens, therefore fast hash is sufficient
+	secret, hash, err := newToken()
+	if err != nil {
+		return Client{}, "", err
+	}
+	client := Client{
+		ClientID:   clientID[:32],
+		Secret:     hash,
+		Name:       name,
+		GrantTypes: grantTypes,
+		Scopes:     scopes,
+		Created:    time.Now().Unix(),
+	}
+	if err := store.AddClient(client); err != nil {
+		return Client{}, "", err
+	}
+	return client, secret, nil
+}
+
+// OAuthServer implements OAuth2 token endpoint for client_credentials,
+// password and refresh_token grants
+type OAuthServer struct {
+	Clients        ClientStore // registered clients
+	Users          UserStore   // local user accounts used by password grant
+	Key            string      // key to sign tokens, e.g. Authz ClientId
+	RefreshKey     string      // key to sign refresh tokens, derived from Key if not set
+	AccessExpires  int64       // access token expiration in seconds, DefaultAccessExpires if not set
+	RefreshExpires int64       // refresh token expiration in seconds, DefaultRefreshExpires if not set
+	Verbose        int         // verbosity level
+}
+
+// Routes registers OAuth handlers within given router
+func (s *OAuthServer) Routes(r gin.IRouter) {
+	r.POST("/oauth/token", s.TokenHandler)
+}
+
+// helper function to write OAuth error response
+func oauthError(c *gin.Context, code, desc string) {
+	status := http.StatusBadRequest
+	if code == OAuthInvalidClient {
+		status = http.StatusUnauthorized
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	} else if code == OAuthServerError {
+		status = http.StatusInternalServerError
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.AbortWithStatusJSON(status, OAuthError{Error: code, Description: desc})
+}
+
+// TokenHandler implements /oauth/token end-point
+func (s *OAuthServer) TokenHandler(c *gin.Context) {
+	event := AuditEvent{
+		Provider: "oauth",
+		ClientIP: c.ClientIP(),
+		Route:    c.FullPath(),
+		Method:   c.Request.Method,
+	}
+	client, err := s.authenticateClient(c)
+	if err != nil {
+		event.Reason = err.Error()
+		AuditLog(event)
+		// store failures are not reported as wrong client credentials
+		if errors.Is(err, ErrClientNotFound) || errors.Is(err, errNoClientCredentials) || errors.Is(err, errClientSecret) {
+			oauthError(c, OAuthInvalidClient, "client authentication failed")
+		} else {
+			oauthError(c, OAuthServerError, "unable to authenticate client")
+		}
+		return
+	}
+	grantType := c.PostForm("grant_type")
+	if grantType == "" {
+		oauthError(c, OAuthInvalidRequest, "missing grant_type")
+		return
+	}
+	if grantType != "client_credentials" && grantType != "password" && grantType != "refresh_token" {
+		oauthError(c, OAuthUnsupportedGrantType, grantType)
+		return
+	}
+	if !utils.InList(grantType, client.GrantTypes) {
+		oauthError(c, OAuthUnauthorizedClient, "grant type is not allowed for the client")
+		return
+	}
+	var claims *Claims
+	var code, desc string
+	switch grantType {
+	case "client_credentials":
+		claims, code, desc = s.clientCredentialsGrant(c, client)
+	case "password":
+		claims, code, desc = s.passwordGrant(c, client)
+	case "refresh_token":
+		claims, code, desc = s.refreshTokenGrant(c, client)
+	}
+	event.Subject = client.ClientID
+	if claims != nil {
+		event.Subject = claims.Subject
+	}
+	if code != "" {
+		event.Reason = desc
+		AuditLog(event)
+		oauthError(c, code, desc)
+		return
+	}
+	token, err := NewToken(claims, s.Key, s.accessExpires())
+	if err != nil {
+		oauthError(c, OAuthServerError, err.Error())
+		return
+	}
+	token.Scope = claims.Scope
+	if grantType != "client_credentials" {
+		token.RefreshToken, err = s.refreshToken(claims)
+		if err != nil {
+			oauthError(c, OAuthServerError, err.Error())
+			return
+		}
+	}
+	event.Success = true
+	AuditLog(event)
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(http.StatusOK, token)
+}
+
+// helper function to authenticate OAuth client using HTTP basic
+// authentication or client_id/client_secret form parameters
+func (s *OAuthServer) authenticateClient(c *gin.Context) (Client, error) {
+	clientID, secret, ok := c.Request.BasicAuth()
+	if !ok {
+		clientID = c.PostForm("client_id")
+		secret = c.PostForm("client_secret")
+	}
+	if clientID == "" || secret == "" {
+		return Client{}, errNoClientCredentials
+	}
+	client, err := s.Clients.GetClient(clientID)
+	if err != nil {
+		return client, err
+	}
+	if !checkClientSecret(client.Secret, secret) {
+		return client, errClientSecret
+	}
+	return client, nil
+}
+
+// helper function to check client secret against its stored hash, secrets
+// of clients registered with password hashes are still accepted
+func checkClientSecret(hash, secret string) bool {
+	if strings.HasPrefix(hash, "$") {
+		return CheckPassword(hash, secret)
+	}
+	return checkToken(hash, secret)
+}
+
+// helper function to get expiration of access tokens
+func (s *OAuthServer) accessExpires() int64 {
+	if s.AccessExpires > 0 {
+		return s.AccessExpires
+	}
+	return DefaultAccessExpires
+}
+
+// helper function to get expiration of refresh tokens
+func (s *OAuthServer) refreshExpires() int64 {
+	if s.RefreshExpires > 0 {
+		return s.RefreshExpires
+	}
+	return DefaultRefreshExpires
+}
+
+// helper function to get key of refresh tokens, refresh tokens are never
+// signed with the key of access tokens
+func (s *OAuthServer) refreshKey() string {
+	if s.RefreshKey != "" {
+		return s.RefreshKey
+	}
+	mac := hmac.New(sha256.New, []byte(s.Key))
+	mac.Write([]byte(refreshAudience))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// helper function to validate requested scope against allowed scopes,
+// it returns allowed scopes if no scope is requested
+func validateScope(requested string, allowed []string) (string, bool) {
+	scopes := strings.Fields(requested)
+	if len(scopes) == 0 {
+		return strings.Join(allowed, " "), true
+	}
+	for _, scope := range scopes {
+		if !utils.InList(scope, allowed) {
+			return "", false
+		}
+	}
+	return strings.Join(scopes, " "), true
+}
+
+// helper function to create claims for given subject
+func (s *OAuthServer) claims(subject, clientID, scope string, amr []string) *Claims {
+	now := time.Now()
+	return &Claims{
+		Login:    subject,
+		AMR:      amr,
+		Scope:    scope,
+		ClientID: clientID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Duration(s.accessExpires()) * time.Second)),
+		},
+	}
+}
+
+// client_credentials grant, see RFC 6749 section 4.4
+func (s *OAuthServer) clientCredentialsGrant(c *gin.Context, client Client) (*Claims, string, string) {
+	scope, ok := validateScope(c.PostForm("scope"), client.Scopes)
+	if !ok {
+		return nil, OAuthInvalidScope, "requested scope is not allowed"
+	}
+	return s.claims(client.ClientID, client.ClientID, scope, nil), "", ""
+}
+
+// password grant, see RFC 6749 section 4.3
+func (s *OAuthServer) passwordGrant(c *gin.Context, client Client) (*Claims, string, string) {
+	login := c.PostForm("username")
+	password := c.PostForm("password")
+	if login == "" || password == "" {
+		return nil, OAuthInvalidRequest, "missing username or password"
+	}
+	scope, ok := validateScope(c.PostForm("scope"), client.Scopes)
+	if !ok {
+		return nil, OAuthInvalidScope, "requested scope is not allowed"
+	}
+	if s.Users == nil {
+		return nil, OAuthUnauthorizedClient, "password grant is not configured"
+	}
+	user, amr, err := AuthenticateMFA(s.Users, login, password, c.PostForm("totp"))
+	if err != nil {
+		if s.Verbose > 0 {
+			log.Printf("WARNING: password grant of %s failed, error %v", login, err)
+		}
+		// do not reveal which part of credentials is wrong, missing second
+		// factor is reported as well since it means the password is correct
+		switch {
+		case errors.Is(err, ErrMFARequired),
+			errors.Is(err, ErrInvalidPassword), errors.Is(err, ErrAccountLocked),
+			errors.Is(err, ErrNotVerified), errors.Is(err, ErrInvalidTOTP),
+			errors.Is(err, ErrTOTPNotEnrolled):
+			return nil, OAuthInvalidGrant, "invalid credentials"
+		}
+		return nil, OAuthServerError, "unable to authenticate user"
+	}
+	if len(amr) > 1 {
+		amr = append(amr, AMRMFA)
+	}
+	return s.claims(user.Login, client.ClientID, scope, amr), "", ""
+}
+
+// refresh_token grant, see RFC 6749 section 6
+func (s *OAuthServer) refreshTokenGrant(c *gin.Context, client Client) (*Claims, string, string) {
+	rtoken := c.PostForm("refresh_token")
+	if rtoken == "" {
+		return nil, OAuthInvalidRequest, "missing refresh_token"
+	}
+	token := &Token{AccessToken: rtoken}
+	rclaims, err := token.Claims(s.refreshKey())
+	if err != nil || !utils.InList(refreshAudience, rclaims.Audience) {
+		return nil, OAuthInvalidGrant, "invalid refresh token"
+	}
+	if rclaims.ClientID != client.ClientID {
+		return nil, OAuthInvalidGrant, "refresh token was issued to another client"
+	}
+	// refresh tokens are issued to local users, their accounts are checked
+	// again and tokens issued before password change are revoked
+	if s.Users == nil {
+		return nil, OAuthInvalidGrant, "invalid refresh token"
+	}
+	user, err := s.Users.GetUser(rclaims.Subject)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, OAuthInvalidGrant, "invalid refresh token"
+	} else if err != nil {
+		if s.Verbose > 0 {
+			log.Printf("ERROR: unable to get user %s, error %v", rclaims.Subject, err)
+		}
+		return nil, OAuthServerError, "unable to authenticate user"
+	}
+	if user.Locked() || !user.Verified || rclaims.IssuedAt == nil || rclaims.IssuedAt.Unix() < user.PassChanged {
+		return nil, OAuthInvalidGrant, "invalid refresh token"
+	}
+	// requested scope must not exceed originally granted one
+	scope, ok := validateScope(c.PostForm("scope"), strings.Fields(rclaims.Scope))
+	if !ok {
+		return nil, OAuthInvalidScope, "requested scope exceeds granted scope"
+	}
+	return s.claims(rclaims.Subject, client.ClientID, scope, rclaims.AMR), "", ""
+}
+
+// helper function to issue refresh token for given claims
+func (s *OAuthServer) refreshToken(claims *Claims) (string, error) {
+	now := time.Now()
+	rclaims := *claims
+	rclaims.RegisteredClaims = jwt.RegisteredClaims{
+		Subject:   claims.Subject,
+		Audience:  jwt.ClaimStrings{refreshAudience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Duration(s.refreshExpires()) * time.Second)),
+	}
+	tkn := jwt.NewWithClaims(jwt.SigningMethodHS256, &rclaims)
+	return tkn.SignedString([]byte(s.refreshKey()))
+}
@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OreCast/common/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// memoryClients implements ClientStore interface for unit tests
+type memoryClients map[string]Client
+
+func (m memoryClients) GetClient(clientID string) (Client, error) {
+	if client, ok := m[clientID]; ok {
+		return client, nil
+	}
+	return Client{}, ErrClientNotFound
+}
+
+func (m memoryClients) AddClient(client Client) error {
+	m[client.ClientID] = client
+	return nil
+}
+
+func (m memoryClients) RemoveClient(clientID string) error {
+	delete(m, clientID)
+	return nil
+}
+
+// helper function to post form to token end-point
+func postToken(r *gin.Engine, form url.Values) (int, map[string]interface{}) {
+	req := httptest.NewRequest("POST", "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	out := make(map[string]interface{})
+	json.Unmarshal(w.Body.Bytes(), &out)
+	return w.Code, out
+}
+
+// TestOAuthTokenHandler
+func TestOAuthTokenHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	clients := make(memoryClients)
+	grants := []string{"client_credentials", "refresh_token"}
+	client, secret, err := RegisterClient(clients, "test", grants, []string{"read", "write"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &OAuthServer{Clients: clients, Key: "key", AccessExpires: 60, RefreshExpires: 600}
+	r := gin.New()
+	srv.Routes(r)
+
+	form := url.Values{"grant_type": {"client_credentials"}, "client_id": {client.ClientID}, "client_secret": {"wrong"}}
+	if code, out := postToken(r, form); code != http.StatusUnauthorized || out["error"] != OAuthInvalidClient {
+		t.Errorf("wrong secret response %d %v", code, out)
+	}
+	form.Set("client_secret", secret)
+	form.Set("scope", "admin")
+	if code, out := postToken(r, form); code != http.StatusBadRequest || out["error"] != OAuthInvalidScope {
+		t.Errorf("wrong scope response %d %v", code, out)
+	}
+	form.Set("scope", "read")
+	code, out := postToken(r, form)
+	if code != http.StatusOK || out["scope"] != "read" {
+		t.Fatalf("client_credentials response %d %v", code, out)
+	}
+	token := &Token{AccessToken: out["access_token"].(string)}
+	if err := token.Validate("key"); err != nil {
+		t.Error(err)
+	}
+	form.Set("grant_type", "password")
+	if code, out := postToken(r, form); out["error"] != OAuthUnauthorizedClient {
+		t.Errorf("not allowed grant response %d %v", code, out)
+	}
+}
+
+// unavailableClients simulates failing client store
+type unavailableClients struct {
+	memoryClients
+}
+
+func (m unavailableClients) GetClient(clientID string) (Client, error) {
+	return Client{}, errors.New("database is unavailable")
+}
+
+// TestOAuthClientErrors
+func TestOAuthClientErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	clients := make(memoryClients)
+	client, secret, err := RegisterClient(clients, "test", []string{"client_credentials"}, []string{"read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// expiration of tokens has default value
+	srv := &OAuthServer{Clients: clients, Key: "key"}
+	r := gin.New()
+	srv.Routes(r)
+	form := url.Values{"grant_type": {"client_credentials"}, "client_id": {client.ClientID}, "client_secret": {secret}}
+	status, out := postToken(r, form)
+	if status != http.StatusOK || out["expires_in"] != float64(DefaultAccessExpires) {
+		t.Fatalf("response without token expiration %d %v", status, out)
+	}
+	token := &Token{AccessToken: out["access_token"].(string)}
+	if err := token.Validate("key"); err != nil {
+		t.Errorf("token without configured expiration is invalid, error %v", err)
+	}
+
+	// store failure is not reported as wrong client credentials
+	srv = &OAuthServer{Clients: unavailableClients{clients}, Key: "key"}
+	r = gin.New()
+	srv.Routes(r)
+	if status, out := postToken(r, form); status != http.StatusInternalServerError || out["error"] != OAuthServerError {
+		t.Errorf("unavailable client store response %d %v", status, out)
+	}
+}
+
+// TestOAuthPasswordGrant
+func TestOAuthPasswordGrant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	TOTPKey = "test"
+	users := make(memoryStore)
+	_, vtoken, err := RegisterUser(users, "alice", "alice@example.com", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyEmail(users, "alice", vtoken); err != nil {
+		t.Fatal(err)
+	}
+	clients := make(memoryClients)
+	grants := []string{"password", "refresh_token"}
+	client, secret, err := RegisterClient(clients, "test", grants, []string{"read", "write"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, otherSecret, err := RegisterClient(clients, "other", grants, []string{"read", "write"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &OAuthServer{Clients: clients, Users: users, Key: "key", AccessExpires: 60, RefreshExpires: 600}
+	r := gin.New()
+	srv.Routes(r)
+
+	// wrong password and unknown user are not distinguished
+	form := url.Values{
+		"grant_type":    {"password"},
+		"client_id":     {client.ClientID},
+		"client_secret": {secret},
+		"username":      {"alice"},
+		"password":      {"wrong"},
+	}
+	_, wrongPassword := postToken(r, form)
+	form.Set("username", "bob")
+	_, unknownUser := postToken(r, form)
+	if wrongPassword["error"] != OAuthInvalidGrant || wrongPassword["error_description"] != unknownUser["error_description"] {
+		t.Errorf("wrong credentials responses %v %v", wrongPassword, unknownUser)
+	}
+
+	// TOTP code is required once TOTP is enrolled
+	tsecret, _, _, err := EnrollTOTP(users, "alice", "OreCast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := TOTPCode(tsecret, time.Now())
+	if err := ConfirmTOTP(users, "alice", code); err != nil {
+		t.Fatal(err)
+	}
+	form.Set("username", "alice")
+	form.Set("password", "secret")
+	if _, out := postToken(r, form); out["error"] != OAuthInvalidGrant || out["error_description"] != wrongPassword["error_description"] {
+		t.Errorf("missing TOTP code response %v", out)
+	}
+	form.Set("totp", "000000")
+	if _, out := postToken(r, form); out["error_description"] != "invalid credentials" {
+		t.Errorf("wrong TOTP code response %v", out)
+	}
+	// code of the next time step is accepted within TOTPWindow
+	code, _ = TOTPCode(tsecret, time.Now().Add(TOTPPeriod))
+	form.Set("totp", code)
+	status, out := postToken(r, form)
+	if status != http.StatusOK {
+		t.Fatalf("password grant response %d %v", status, out)
+	}
+	token := &Token{AccessToken: out["access_token"].(string)}
+	claims, err := token.Claims("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Login != "alice" || !utils.InList(AMROTP, claims.AMR) || !utils.InList(AMRMFA, claims.AMR) {
+		t.Errorf("wrong password grant claims %+v", claims)
+	}
+
+	// refresh token is not signed with access token key
+	rtoken := out["refresh_token"].(string)
+	if err := (&Token{AccessToken: rtoken}).Validate("key"); err == nil {
+		t.Error("refresh token is accepted as access token")
+	}
+
+	// refresh token rotation with scope narrowing
+	form = url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {client.ClientID},
+		"client_secret": {secret},
+		"refresh_token": {rtoken},
+		"scope":         {"read"},
+	}
+	status, out = postToken(r, form)
+	if status != http.StatusOK || out["scope"] != "read" {
+		t.Fatalf("refresh_token response %d %v", status, out)
+	}
+	rotated, _ := out["refresh_token"].(string)
+	if rotated == "" || rotated == rtoken {
+		t.Errorf("refresh token is not rotated %v", out)
+	}
+	form.Set("refresh_token", rotated)
+	form.Set("scope", "write")
+	if _, out := postToken(r, form); out["error"] != OAuthInvalidScope {
+		t.Errorf("scope wider than granted one response %v", out)
+	}
+
+	// refresh token is bound to its client
+	form.Set("client_id", other.ClientID)
+	form.Set("client_secret", otherSecret)
+	form.Set("scope", "read")
+	if _, out := postToken(r, form); out["error"] != OAuthInvalidGrant {
+		t.Errorf("refresh token of another client response %v", out)
+	}
+
+	// refresh tokens of locked accounts and tokens issued before password
+	// change are rejected
+	form.Set("client_id", client.ClientID)
+	form.Set("client_secret", secret)
+	if status, out := postToken(r, form); status != http.StatusOK {
+		t.Fatalf("refresh_token response %d %v", status, out)
+	}
+	user := users["alice"]
+	user.LockedUntil = time.Now().Add(time.Minute).Unix()
+	users["alice"] = user
+	if _, out := postToken(r, form); out["error"] != OAuthInvalidGrant {
+		t.Errorf("refresh token of locked account response %v", out)
+	}
+	user.LockedUntil = 0
+	user.PassChanged = time.Now().Add(time.Second).Unix()
+	users["alice"] = user
+	if _, out := postToken(r, form); out["error"] != OAuthInvalidGrant {
+		t.Errorf("refresh token issued before password change response %v", out)
+	}
+	delete(users, "alice")
+	if _, out := postToken(r, form); out["error"] != OAuthInvalidGrant {
+		t.Errorf("refresh token of removed account response %v", out)
+	}
+}
@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OreCast/common/utils"
+)
+
+// errors returned by group and role management functions
+var (
+	ErrRoleNotFound  = errors.New("role not found")
+	ErrGroupNotFound = errors.New("group not found")
+)
+
+// Role represents named set of permissions
+type Role struct {
+	Name        string   `json:"name"`        // role name
+	Permissions []string `json:"permissions"` // permissions granted by the role
+}
+
+// Group represents group of users which grants roles to its members
+type Group struct {
+	Name    string   `json:"name"`    // group name
+	Members []string `json:"members"` // user logins
+	Roles   []string `json:"roles"`   // role names
+}
+
+// RoleStore defines interface to persist groups and roles
+type RoleStore interface {
+	GetRole(name string) (Role, error)
+	Roles() ([]Role, error)
+	SaveRole(role Role) error
+	RemoveRole(name string) error
+	GetGroup(name string) (Group, error)
+	Groups() ([]Group, error)
+	SaveGroup(group Group) error
+	RemoveGroup(name string) error
+	UserGroups(login string) ([]Group, error)
+	// AddMember and RemoveMember atomically change members of given group,
+	// they return ErrGroupNotFound error for unknown groups
+	AddMember(group, login string) error
+	RemoveMember(group, login string) error
+}
+
+// roleEntry represents cached roles and permissions of a user
+type roleEntry struct {
+	roles       []string
+	permissions []string
+	expires     time.Time
+}
+
+// RoleResolver resolves locally assigned roles and permissions of users,
+// resolved entries are cached for TTL period and invalidated on every
+// modification done through the resolver
+type RoleResolver struct {
+	Store      RoleStore     // groups and roles store
+	TTL        time.Duration // cache validity period
+	Issuers    []string      // token issuers whose users get local roles in Augment
+	cache      map[string]roleEntry
+	generation uint64 // incremented on every invalidation
+	mutex      sync.RWMutex
+}
+
+// NewRoleResolver creates new role resolver for given store
+func NewRoleResolver(store RoleStore, ttl time.Duration) *RoleResolver {
+	return &RoleResolver{Store: store, TTL: ttl, cache: make(map[string]roleEntry)}
+}
+
+// helper function to resolve roles and permissions of given user
+func (r *RoleResolver) resolve(login string) (roleEntry, error) {
+	r.mutex.RLock()
+	entry, ok := r.cache[login]
+	generation := r.generation
+	r.mutex.RUnlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry, nil
+	}
+	groups, err := r.Store.UserGroups(login)
+	if err != nil {
+		return entry, err
+	}
+	entry = roleEntry{expires: time.Now().Add(r.TTL)}
+	for _, group := range groups {
+		for _, name := range group.Roles {
+			if utils.InList(name, entry.roles) {
+				continue
+			}
+			// roles removed from the store are not granted
+			role, err := r.Store.GetRole(name)
+			if errors.Is(err, ErrRoleNotFound) {
+				continue
+			} else if err != nil {
+				return entry, err
+			}
+			entry.roles = append(entry.roles, name)
+			for _, perm := range role.Permissions {
+				if !utils.InList(perm, entry.permissions) {
+					entry.permissions = append(entry.permissions, perm)
+				}
+			}
+		}
+	}
+	// do not cache entry resolved before concurrent invalidation
+	r.mutex.Lock()
+	if r.cache == nil {
+		r.cache = make(map[string]roleEntry)
+	}
+	if r.generation == generation {
+		r.cache[login] = entry
+	}
+	r.mutex.Unlock()
+	return entry, nil
+}
+
+// Roles returns roles of given user
+func (r *RoleResolver) Roles(login string) ([]string, error) {
+	entry, err := r.resolve(login)
+	return entry.roles, err
+}
+
+// Permissions returns permissions of given user
+func (r *RoleResolver) Permissions(login string) ([]string, error) {
+	entry, err := r.resolve(login)
+	return entry.permissions, err
+}
+
+// HasPermission checks if given user has given permission
+func (r *RoleResolver) HasPermission(login, permission string) bool {
+	perms, err := r.Permissions(login)
+	if err != nil {
+		return false
+	}
+	return utils.InList(permission, perms)
+}
+
+// Augment adds locally assigned roles to the scope of token attributes,
+// only tokens of Issuers are augmented since user names of other issuers
+// may match local logins of different users
+func (r *RoleResolver) Augment(attrs *TokenAttributes) error {
+	if !utils.InList(attrs.Issuer, r.Issuers) {
+		return nil
+	}
+	login := attrs.UserName
+	if login == "" {
+		login = attrs.Subject
+	}
+	roles, err := r.Roles(login)
+	if err != nil {
+		return err
+	}
+	scope := strings.Fields(attrs.Scope)
+	for _, role := range roles {
+		if !utils.InList(role, scope) {
+			scope = append(scope, role)
+		}
+	}
+	attrs.Scope = strings.Join(scope, " ")
+	return nil
+}
+
+// Invalidate removes cached entry of given user
+func (r *RoleResolver) Invalidate(login string) {
+	r.mutex.Lock()
+	delete(r.cache, login)
+	r.generation++
+	r.mutex.Unlock()
+}
+
+// InvalidateAll removes all cached entries
+func (r *RoleResolver) InvalidateAll() {
+	r.mutex.Lock()
+	r.cache = make(map[string]roleEntry)
+	r.generation++
+	r.mutex.Unlock()
+}
+
+// SaveRole creates or updates role and invalidates the cache
+func (r *RoleResolver) SaveRole(role Role) error {
+	defer r.InvalidateAll()
+	return r.Store.SaveRole(role)
+}
+
+// RemoveRole removes role and invalidates the cache
+func (r *RoleResolver) RemoveRole(name string) error {
+	defer r.InvalidateAll()
+	return r.Store.RemoveRole(name)
+}
+
+// SaveGroup creates or updates group and invalidates the cache
+func (r *RoleResolver) SaveGroup(group Group) error {
+	defer r.InvalidateAll()
+	return r.Store.SaveGroup(group)
+}
+
+// RemoveGroup removes group and invalidates the cache
+func (r *RoleResolver) RemoveGroup(name string) error {
+	defer r.InvalidateAll()
+	return r.Store.RemoveGroup(name)
+}
+
+// AddMember adds user to given group and invalidates user's cache entry
+func (r *RoleResolver) AddMember(group, login string) error {
+	defer r.Invalidate(login)
+	return r.Store.AddMember(group, login)
+}
+
+// RemoveMember removes user from given group and invalidates user's cache entry
+func (r *RoleResolver) RemoveMember(group, login string) error {
+	defer r.Invalidate(login)
+	return r.Store.RemoveMember(group, login)
+}
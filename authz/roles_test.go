@@ -0,0 +1,302 @@
+package auth
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryRoleStore implements RoleStore interface for unit tests
+type memoryRoleStore struct {
+	roles  map[string]Role
+	groups map[string]Group
+	calls  int // number of UserGroups calls
+	mutex  sync.Mutex
+}
+
+func newMemoryRoleStore() *memoryRoleStore {
+	return &memoryRoleStore{roles: make(map[string]Role), groups: make(map[string]Group)}
+}
+
+func (m *memoryRoleStore) GetRole(name string) (Role, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if role, ok := m.roles[name]; ok {
+		return role, nil
+	}
+	return Role{}, ErrRoleNotFound
+}
+
+func (m *memoryRoleStore) Roles() ([]Role, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var out []Role
+	for _, role := range m.roles {
+		out = append(out, role)
+	}
+	return out, nil
+}
+
+func (m *memoryRoleStore) SaveRole(role Role) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.roles[role.Name] = role
+	return nil
+}
+
+func (m *memoryRoleStore) RemoveRole(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.roles, name)
+	return nil
+}
+
+func (m *memoryRoleStore) GetGroup(name string) (Group, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if group, ok := m.groups[name]; ok {
+		return group, nil
+	}
+	return Group{}, ErrGroupNotFound
+}
+
+func (m *memoryRoleStore) Groups() ([]Group, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var out []Group
+	for _, group := range m.groups {
+		out = append(out, group)
+	}
+	return out, nil
+}
+
+func (m *memoryRoleStore) SaveGroup(group Group) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.groups[group.Name] = group
+	return nil
+}
+
+func (m *memoryRoleStore) RemoveGroup(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.groups, name)
+	return nil
+}
+
+func (m *memoryRoleStore) UserGroups(login string) ([]Group, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.calls++
+	var out []Group
+	for _, group := range m.groups {
+		for _, member := range group.Members {
+			if member == login {
+				out = append(out, group)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (m *memoryRoleStore) AddMember(group, login string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	grp, ok := m.groups[group]
+	if !ok {
+		return ErrGroupNotFound
+	}
+	for _, member := range grp.Members {
+		if member == login {
+			return nil
+		}
+	}
+	grp.Members = append(grp.Members, login)
+	m.groups[group] = grp
+	return nil
+}
+
+func (m *memoryRoleStore) RemoveMember(group, login string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	grp, ok := m.groups[group]
+	if !ok {
+		return ErrGroupNotFound
+	}
+	var members []string
+	for _, member := range grp.Members {
+		if member != login {
+			members = append(members, member)
+		}
+	}
+	grp.Members = members
+	m.groups[group] = grp
+	return nil
+}
+
+// helper function to create resolver with admins and readers groups
+func testResolver(t *testing.T) (*RoleResolver, *memoryRoleStore) {
+	store := newMemoryRoleStore()
+	resolver := NewRoleResolver(store, time.Minute)
+	for _, role := range []Role{
+		{Name: "admin", Permissions: []string{"read", "write", "delete"}},
+		{Name: "reader", Permissions: []string{"read"}},
+	} {
+		if err := resolver.SaveRole(role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, group := range []Group{
+		{Name: "admins", Members: []string{"alice"}, Roles: []string{"admin", "missing"}},
+		{Name: "readers", Members: []string{"alice", "bob"}, Roles: []string{"reader"}},
+	} {
+		if err := resolver.SaveGroup(group); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resolver, store
+}
+
+// TestRoleInheritance
+func TestRoleInheritance(t *testing.T) {
+	resolver, _ := testResolver(t)
+	roles, err := resolver.Roles("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// roles missing in the store are not granted
+	if strings.Join(roles, ",") != "admin,reader" {
+		t.Errorf("wrong roles of alice %v", roles)
+	}
+	perms, err := resolver.Permissions("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// permissions of all roles are merged without duplicates
+	if strings.Join(perms, ",") != "read,write,delete" {
+		t.Errorf("wrong permissions of alice %v", perms)
+	}
+	if !resolver.HasPermission("bob", "read") || resolver.HasPermission("bob", "write") {
+		t.Error("wrong permissions of bob")
+	}
+	if resolver.HasPermission("carol", "read") {
+		t.Error("user without groups has permissions")
+	}
+	resolver.Issuers = []string{"https://idp.example.com"}
+	attrs := TokenAttributes{Subject: "bob", Issuer: "https://idp.example.com", Scope: "openid reader"}
+	if err := resolver.Augment(&attrs); err != nil {
+		t.Fatal(err)
+	}
+	if attrs.Scope != "openid reader" {
+		t.Errorf("wrong augmented scope %q", attrs.Scope)
+	}
+	// tokens of other issuers do not get local roles
+	attrs = TokenAttributes{Subject: "alice", Issuer: "https://other.example.com", Scope: "openid"}
+	if err := resolver.Augment(&attrs); err != nil {
+		t.Fatal(err)
+	}
+	if attrs.Scope != "openid" {
+		t.Errorf("token of other issuer is augmented %q", attrs.Scope)
+	}
+}
+
+// TestGroupMembership
+func TestGroupMembership(t *testing.T) {
+	resolver, _ := testResolver(t)
+	if resolver.HasPermission("bob", "delete") {
+		t.Fatal("bob is admin before membership")
+	}
+	if err := resolver.AddMember("admins", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if !resolver.HasPermission("bob", "delete") {
+		t.Error("new group member does not get group permissions")
+	}
+	if err := resolver.RemoveMember("admins", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if resolver.HasPermission("bob", "delete") {
+		t.Error("removed group member keeps group permissions")
+	}
+	if err := resolver.AddMember("unknown", "bob"); err != ErrGroupNotFound {
+		t.Errorf("membership of unknown group returns %v", err)
+	}
+}
+
+// TestRoleCache
+func TestRoleCache(t *testing.T) {
+	resolver, store := testResolver(t)
+	resolver.Permissions("alice")
+	resolver.Permissions("alice")
+	if store.calls != 1 {
+		t.Errorf("cached entry is not used, %d store calls", store.calls)
+	}
+
+	// changes done directly in the store are visible only after invalidation
+	resolver.Permissions("bob")
+	store.SaveRole(Role{Name: "reader", Permissions: []string{"read", "list", "export"}})
+	if resolver.HasPermission("bob", "export") {
+		t.Error("cached permissions are not used")
+	}
+	resolver.Invalidate("bob")
+	if !resolver.HasPermission("bob", "export") {
+		t.Error("invalidated entry is not resolved again")
+	}
+	store.SaveRole(Role{Name: "admin", Permissions: []string{"admin"}})
+	resolver.InvalidateAll()
+	if !resolver.HasPermission("alice", "admin") {
+		t.Error("entries are not invalidated")
+	}
+
+	// expired entries are resolved again
+	resolver.TTL = 0
+	resolver.InvalidateAll()
+	calls := store.calls
+	resolver.Permissions("alice")
+	resolver.Permissions("alice")
+	if store.calls != calls+2 {
+		t.Errorf("expired entry is used, %d store calls", store.calls-calls)
+	}
+}
+
+// blockingRoleStore blocks first UserGroups call until release channel is closed
+type blockingRoleStore struct {
+	*memoryRoleStore
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (b *blockingRoleStore) UserGroups(login string) ([]Group, error) {
+	groups, err := b.memoryRoleStore.UserGroups(login)
+	b.once.Do(func() {
+		close(b.started)
+		<-b.release
+	})
+	return groups, err
+}
+
+// TestRoleCacheInvalidationRace
+func TestRoleCacheInvalidationRace(t *testing.T) {
+	_, mem := testResolver(t)
+	store := &blockingRoleStore{memoryRoleStore: mem, started: make(chan struct{}), release: make(chan struct{})}
+	resolver := NewRoleResolver(store, time.Minute)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		resolver.Permissions("bob")
+	}()
+	// group is modified while resolver reads stale groups
+	<-store.started
+	if err := resolver.AddMember("admins", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	close(store.release)
+	<-done
+	if !resolver.HasPermission("bob", "delete") {
+		t.Error("stale entry is cached after invalidation")
+	}
+}
@@ -2,31 +2,54 @@ package auth
 
 import (
 	"errors"
-	"log"
 
+	"github.com/OreCast/common/utils"
 	jwt "github.com/golang-jwt/jwt/v4"
 )
 
+// Response represents response of authz user APIs, see UserResponse
 type Response struct {
 	Status string `json:"status"`
 	Uid    int    `json:"uid,omitempty"`
 	Error  string `json:"error,omitempty"`
 }
 
+// Claims represents claims of tokens issued to local users, see UserClaims
 type Claims struct {
-	Login string `json:"login"`
+	Login    string   `json:"login"`
+	AMR      []string `json:"amr,omitempty"`       // authentication methods references
+	Scope    string   `json:"scope,omitempty"`     // granted scope
+	ClientID string   `json:"client_id,omitempty"` // OAuth client the token was issued to
 	jwt.RegisteredClaims
 }
 
 // Token represents access token structure
 type Token struct {
-	AccessToken string `json:"access_token"`
-	Expires     int    `json:"expires_in"`
-	Scope       string `json:"scope"`
-	TokenType   string `json:"token_type"`
+	AccessToken  string `json:"access_token"`
+	Expires      int    `json:"expires_in"`
+	Scope        string `json:"scope"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token,omitempty"`
 }
 
+// Validate validates token with given client id
 func (t *Token) Validate(clientId string) error {
+	_, err := t.accessClaims(clientId)
+	return err
+}
+
+// helper function to validate access token and return its claims,
+// refresh tokens are not accepted as access tokens
+func (t *Token) accessClaims(clientId string) (*Claims, error) {
+	claims, err := t.Claims(clientId)
+	if err == nil && utils.InList(refreshAudience, claims.Audience) {
+		return claims, errors.New("refresh token is used as access token")
+	}
+	return claims, err
+}
+
+// Claims validates token with given client id and returns its claims
+func (t *Token) Claims(clientId string) (*Claims, error) {
 	// validate our token
 	var jwtKey = []byte(clientId)
 	claims := &Claims{}
@@ -35,13 +58,12 @@ func (t *Token) Validate(clientId string) error {
 	})
 	if err != nil {
 		if err == jwt.ErrSignatureInvalid {
-			return errors.New("invalid signature")
-			log.Fatal(err)
+			return claims, errors.New("invalid signature")
 		}
-		return err
+		return claims, err
 	}
 	if !tkn.Valid {
-		return errors.New("invalid token")
+		return claims, errors.New("invalid token")
 	}
-	return nil
+	return claims, nil
 }
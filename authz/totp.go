@@ -0,0 +1,323 @@
+package auth
+
+// RFC 6238 time-based one-time passwords for local user accounts
+// https://datatracker.ietf.org/doc/html/rfc6238
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// authentication method references used in amr claim, see RFC 8176
+const (
+	AMRPassword = "pwd" // password based authentication
+	AMROTP      = "otp" // one-time password
+	AMRMFA      = "mfa" // multiple-factor authentication
+)
+
+// errors returned by TOTP functions
+var (
+	ErrTOTPNotEnrolled = errors.New("TOTP is not enrolled")
+	ErrInvalidTOTP     = errors.New("invalid TOTP code")
+	ErrTOTPEnabled     = errors.New("TOTP is already enabled")
+)
+
+// TOTPPeriod defines TOTP time step
+var TOTPPeriod = 30 * time.Second
+
+// TOTPDigits defines number of digits in TOTP code
+var TOTPDigits = 6
+
+// TOTPWindow defines number of time steps accepted before and after current one
+var TOTPWindow = 1
+
+// RecoveryCodes defines number of recovery codes generated at enrollment
+var RecoveryCodes = 10
+
+// TOTPKey defines key to encrypt TOTP secrets stored in user records,
+// it must be set before TOTP enrollment
+var TOTPKey string
+
+// b32 defines encoding of TOTP secrets and recovery codes
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret generates new base32 encoded TOTP secret
+func GenerateTOTPSecret() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return b32.EncodeToString(buf), nil
+}
+
+// TOTPURI returns otpauth URI of given secret suitable for QR codes
+func TOTPURI(issuer, account, secret string) string {
+	label := url.PathEscape(fmt.Sprintf("%s:%s", issuer, account))
+	vals := url.Values{}
+	vals.Set("secret", secret)
+	vals.Set("issuer", issuer)
+	vals.Set("algorithm", "SHA1")
+	vals.Set("digits", fmt.Sprintf("%d", TOTPDigits))
+	vals.Set("period", fmt.Sprintf("%d", int(TOTPPeriod.Seconds())))
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, vals.Encode())
+}
+
+// helper function to compute HOTP code for given counter, see RFC 4226
+func hotp(key []byte, counter int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, code%mod)
+}
+
+// helper function to create cipher of TOTP secrets
+func totpCipher() (cipher.AEAD, error) {
+	if TOTPKey == "" {
+		return nil, errors.New("TOTP encryption key is not set")
+	}
+	key := sha256.Sum256([]byte(TOTPKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// helper function to encrypt TOTP secret with TOTPKey
+func encryptTOTPSecret(secret string) (string, error) {
+	gcm, err := totpCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	data := gcm.Seal(nonce, nonce, []byte(secret), nil)
+	return base64.RawStdEncoding.EncodeToString(data), nil
+}
+
+// helper function to decrypt TOTP secret with TOTPKey
+func decryptTOTPSecret(secret string) (string, error) {
+	gcm, err := totpCipher()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.RawStdEncoding.DecodeString(secret)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return "", errors.New("invalid encrypted TOTP secret")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("unable to decrypt TOTP secret")
+	}
+	return string(plain), nil
+}
+
+// helper function to check if given code is TOTP code rather than
+// recovery code
+func isTOTPCode(code string) bool {
+	if len(code) != TOTPDigits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// helper function to decode TOTP secret
+func totpKey(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	return b32.DecodeString(strings.TrimRight(secret, "="))
+}
+
+// helper function to return TOTP time step of given time
+func totpStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod.Seconds())
+}
+
+// TOTPCode returns TOTP code of given secret at given time
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := totpKey(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, totpStep(t)), nil
+}
+
+// helper function to find time step matching given code within TOTPWindow,
+// it returns zero if code does not match
+func matchTOTP(secret, code string, t time.Time) (int64, error) {
+	key, err := totpKey(secret)
+	if err != nil {
+		return 0, err
+	}
+	step := totpStep(t)
+	for i := -TOTPWindow; i <= TOTPWindow; i++ {
+		if subtle.ConstantTimeCompare([]byte(hotp(key, step+int64(i))), []byte(code)) == 1 {
+			return step + int64(i), nil
+		}
+	}
+	return 0, nil
+}
+
+// EnrollTOTP generates TOTP secret and recovery codes for given user,
+// TOTP becomes active only after ConfirmTOTP call with valid code. Users
+// with enabled TOTP are rejected with ErrTOTPEnabled error, their TOTP
+// should be disabled by DisableTOTP before new enrollment.
+func EnrollTOTP(store UserStore, login, issuer string) (string, string, []string, error) {
+	user, err := store.GetUser(login)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if user.TOTPEnabled {
+		return "", "", nil, ErrTOTPEnabled
+	}
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return "", "", nil, err
+	}
+	encrypted, err := encryptTOTPSecret(secret)
+	if err != nil {
+		return "", "", nil, err
+	}
+	var codes, hashes []string
+	for i := 0; i < RecoveryCodes; i++ {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return "", "", nil, err
+		}
+		code := strings.ToLower(b32.EncodeToString(buf))
+		codes = append(codes, code)
+		hashes = append(hashes, tokenHash(code))
+	}
+	user.TOTPSecret = encrypted
+	user.TOTPEnabled = false
+	user.RecoveryCodes = hashes
+	if err := store.UpdateTOTP(user); err != nil {
+		return "", "", nil, err
+	}
+	return secret, TOTPURI(issuer, user.Login, secret), codes, nil
+}
+
+// ConfirmTOTP activates TOTP of given user after verification of its first code
+func ConfirmTOTP(store UserStore, login, code string) error {
+	return verifyTOTP(store, login, code, true)
+}
+
+// VerifyTOTP verifies TOTP code of given user, every code can be used only once
+func VerifyTOTP(store UserStore, login, code string) error {
+	return verifyTOTP(store, login, code, false)
+}
+
+// helper function to verify TOTP code and store last used time step,
+// invalid codes count towards account lockout
+func verifyTOTP(store UserStore, login, code string, enroll bool) error {
+	user, err := store.GetUser(login)
+	if err != nil {
+		return err
+	}
+	if user.TOTPSecret == "" || (!enroll && !user.TOTPEnabled) {
+		return ErrTOTPNotEnrolled
+	}
+	if user.Locked() {
+		return ErrAccountLocked
+	}
+	secret, err := decryptTOTPSecret(user.TOTPSecret)
+	if err != nil {
+		return err
+	}
+	step, err := matchTOTP(secret, code, time.Now())
+	if err != nil {
+		return err
+	}
+	// reject unknown codes and replays of already used ones, the step is
+	// stored atomically to reject concurrent replays too
+	used := false
+	if step > user.TOTPLastStep {
+		if used, err = store.UseTOTPStep(login, step); err != nil {
+			return err
+		}
+	}
+	if !used {
+		if err := loginFailure(store, login); err != nil {
+			return err
+		}
+		return ErrInvalidTOTP
+	}
+	if !user.TOTPEnabled {
+		user.TOTPEnabled = true
+		if err := store.UpdateTOTP(user); err != nil {
+			return err
+		}
+	}
+	return loginSuccess(store, &user)
+}
+
+// UseRecoveryCode verifies and consumes recovery code of given user
+func UseRecoveryCode(store UserStore, login, code string) error {
+	user, err := store.GetUser(login)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return ErrTOTPNotEnrolled
+	}
+	if user.Locked() {
+		return ErrAccountLocked
+	}
+	code = strings.ToLower(strings.TrimSpace(code))
+	// every code is removed atomically to be used only once
+	for _, hash := range user.RecoveryCodes {
+		if checkToken(hash, code) {
+			removed, err := store.RemoveRecoveryCode(login, hash)
+			if err != nil {
+				return err
+			}
+			if removed {
+				return loginSuccess(store, &user)
+			}
+			break
+		}
+	}
+	if err := loginFailure(store, login); err != nil {
+		return err
+	}
+	return ErrInvalidTOTP
+}
+
+// DisableTOTP removes TOTP secret and recovery codes of given user
+func DisableTOTP(store UserStore, login string) error {
+	user, err := store.GetUser(login)
+	if err != nil {
+		return err
+	}
+	user.TOTPSecret = ""
+	user.TOTPEnabled = false
+	user.RecoveryCodes = nil
+	return store.UpdateTOTP(user)
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"encoding/base32"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/OreCast/common/utils"
+)
+
+// TestTOTPCode checks TOTP codes against RFC 6238 test vectors
+func TestTOTPCode(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for tstamp, expect := range vectors {
+		code, err := TOTPCode(secret, time.Unix(tstamp, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != expect {
+			t.Errorf("wrong TOTP code at %d: %s != %s", tstamp, code, expect)
+		}
+	}
+}
+
+// TestTOTPEnrollment
+func TestTOTPEnrollment(t *testing.T) {
+	TOTPKey = "test"
+	store := memoryStore{"alice": User{Login: "alice"}}
+	secret, uri, codes, err := EnrollTOTP(store, "alice", "OreCast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri == "" || len(codes) != RecoveryCodes {
+		t.Fatalf("wrong enrollment uri=%s codes=%v", uri, codes)
+	}
+	if store["alice"].TOTPSecret == secret {
+		t.Error("TOTP secret is stored in plain text")
+	}
+	code, _ := TOTPCode(secret, time.Now())
+	if err := VerifyTOTP(store, "alice", code); !errors.Is(err, ErrTOTPNotEnrolled) {
+		t.Errorf("unconfirmed TOTP verification returns %v", err)
+	}
+	if err := ConfirmTOTP(store, "alice", code); err != nil {
+		t.Fatal(err)
+	}
+	// enabled TOTP can not be replaced by new enrollment
+	if _, _, _, err := EnrollTOTP(store, "alice", "OreCast"); !errors.Is(err, ErrTOTPEnabled) {
+		t.Errorf("enrollment of enabled TOTP returns %v", err)
+	}
+	// the same code can not be replayed
+	if err := VerifyTOTP(store, "alice", code); !errors.Is(err, ErrInvalidTOTP) {
+		t.Errorf("replayed TOTP code returns %v", err)
+	}
+	if err := UseRecoveryCode(store, "alice", codes[0]); err != nil {
+		t.Error(err)
+	}
+	if err := UseRecoveryCode(store, "alice", codes[0]); !errors.Is(err, ErrInvalidTOTP) {
+		t.Errorf("reused recovery code returns %v", err)
+	}
+	claims := UserClaims(store["alice"], 60, AMRPassword, AMROTP)
+	if !utils.InList(AMRMFA, claims.AMR) {
+		t.Errorf("missing mfa in amr claim %v", claims.AMR)
+	}
+	claims = UserClaims(store["alice"], 60, AMRPassword, AMRPassword, AMRMFA)
+	if utils.InList(AMRMFA, claims.AMR) || len(claims.AMR) != 1 {
+		t.Errorf("wrong amr claim of single method %v", claims.AMR)
+	}
+}
+
+// TestAuthenticateMFA
+func TestAuthenticateMFA(t *testing.T) {
+	TOTPKey = "test"
+	store := make(memoryStore)
+	_, vtoken, err := RegisterUser(store, "bob", "bob@example.com", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyEmail(store, "bob", vtoken); err != nil {
+		t.Fatal(err)
+	}
+	secret, _, codes, err := EnrollTOTP(store, "bob", "OreCast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := TOTPCode(secret, time.Now())
+	if err := ConfirmTOTP(store, "bob", code); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Authenticate(store, "bob", "secret"); !errors.Is(err, ErrMFARequired) {
+		t.Errorf("login without second factor returns %v", err)
+	}
+	_, amr, err := AuthenticateMFA(store, "bob", "secret", codes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !utils.InList(AMROTP, amr) {
+		t.Errorf("missing otp in amr %v", amr)
+	}
+
+	// lock account with wrong codes
+	for i := 0; i < MaxLoginFailures; i++ {
+		if _, _, err := AuthenticateMFA(store, "bob", "secret", "000000"); err == nil {
+			t.Fatal("wrong TOTP code is accepted")
+		}
+	}
+	if _, _, err := AuthenticateMFA(store, "bob", "secret", codes[1]); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("locked account login returns %v", err)
+	}
+}
+
+// staleStore returns user records read before concurrent updates
+type staleStore struct {
+	memoryStore
+	stale User
+}
+
+func (s staleStore) GetUser(login string) (User, error) {
+	return s.stale, nil
+}
+
+// TestTOTPConcurrentReplay
+func TestTOTPConcurrentReplay(t *testing.T) {
+	TOTPKey = "test"
+	store := memoryStore{"alice": User{Login: "alice"}}
+	secret, _, codes, err := EnrollTOTP(store, "alice", "OreCast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := TOTPCode(secret, time.Now())
+	if err := ConfirmTOTP(store, "alice", code); err != nil {
+		t.Fatal(err)
+	}
+	stale := store["alice"]
+	if err := UseRecoveryCode(store, "alice", codes[0]); err != nil {
+		t.Fatal(err)
+	}
+	stale.TOTPLastStep = 0
+	sstore := staleStore{memoryStore: store, stale: stale}
+	if err := VerifyTOTP(sstore, "alice", code); !errors.Is(err, ErrInvalidTOTP) {
+		t.Errorf("concurrently replayed TOTP code returns %v", err)
+	}
+	if err := UseRecoveryCode(sstore, "alice", codes[0]); !errors.Is(err, ErrInvalidTOTP) {
+		t.Errorf("concurrently reused recovery code returns %v", err)
+	}
+}
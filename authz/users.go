@@ -0,0 +1,423 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OreCast/common/utils"
+	jwt "github.com/golang-jwt/jwt/v4"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// errors returned by user management functions
+var (
+	ErrUserNotFound    = errors.New("user not found")
+	ErrUserExists      = errors.New("user already exists")
+	ErrUserIDExists    = errors.New("user id already exists")
+	ErrInvalidPassword = errors.New("invalid login or password")
+	ErrAccountLocked   = errors.New("account is locked")
+	ErrNotVerified     = errors.New("email is not verified")
+	ErrMFARequired     = errors.New("second authentication factor is required")
+	ErrInvalidToken    = errors.New("invalid or expired token")
+)
+
+// PasswordAlgorithm defines hashing algorithm of new passwords, argon2id or bcrypt
+var PasswordAlgorithm = "argon2id"
+
+// MaxLoginFailures defines number of failed logins before account is locked
+var MaxLoginFailures = 5
+
+// LockoutPeriod defines how long account stays locked
+var LockoutPeriod = 15 * time.Minute
+
+// ResetTokenExpires defines validity period of password reset tokens
+var ResetTokenExpires = time.Hour
+
+// argon2id parameters
+const (
+	argonTime    = 1
+	argonMemory  = 64 * 1024
+	argonThreads = 4
+	argonKeyLen  = 32
+	argonSaltLen = 16
+)
+
+// User represents local user account
+type User struct {
+	Uid          int    `json:"uid"`          // user id
+	Login        string `json:"login"`        // user login name
+	Email        string `json:"email"`        // user email address
+	Password     string `json:"-"`            // password hash
+	Verified     bool   `json:"verified"`     // email verification status
+	VerifyToken  string `json:"-"`            // hash of email verification token
+	ResetToken   string `json:"-"`            // hash of password reset token
+	ResetExpires int64  `json:"-"`            // expiration of password reset token
+	Failures     int    `json:"-"`            // number of consecutive login failures
+	LockedUntil  int64  `json:"locked_until"` // account lockout expiration
+	Created      int64  `json:"created"`      // creation time
+	PassChanged  int64  `json:"-"`            // time of last password change
+
+	// multi-factor authentication parts
+	TOTPSecret    string   `json:"-"`            // encrypted TOTP secret
+	TOTPEnabled   bool     `json:"totp_enabled"` // TOTP enrollment is confirmed
+	TOTPLastStep  int64    `json:"-"`            // last used TOTP time step
+	RecoveryCodes []string `json:"-"`            // hashes of recovery codes
+}
+
+// Locked checks if user account is locked
+func (u *User) Locked() bool {
+	return u.LockedUntil > time.Now().Unix()
+}
+
+// UserStore defines interface to persist user accounts, failed logins are
+// counted by atomic updates which do not overwrite concurrent changes
+type UserStore interface {
+	GetUser(login string) (User, error)
+	AddUser(user User) error
+	// UpdateUser updates existing user account except failed logins,
+	// lockout and TOTP fields which are changed only by methods below
+	UpdateUser(user User) error
+	// RecordFailure atomically increments failed logins of given user and
+	// once they reach max resets them and locks the account until given time
+	RecordFailure(login string, max int, lockUntil int64) error
+	// ResetFailures resets failed logins and lockout of given user
+	ResetFailures(login string) error
+	// UpdateTOTP updates TOTP secret, status and recovery codes of given user
+	UpdateTOTP(user User) error
+	// UseTOTPStep atomically stores used TOTP time step if it is greater
+	// than last used one, it returns false for already used steps
+	UseTOTPStep(login string, step int64) (bool, error)
+	// RemoveRecoveryCode atomically removes hash of recovery code, it
+	// returns false if the code was already used
+	RemoveRecoveryCode(login, hash string) (bool, error)
+}
+
+// HashPassword creates hash of given password using PasswordAlgorithm
+func HashPassword(password string) (string, error) {
+	if PasswordAlgorithm == "bcrypt" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		return string(hash), err
+	}
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+	hash := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argonMemory, argonTime, argonThreads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key))
+	return hash, nil
+}
+
+// CheckPassword checks given password against argon2id or bcrypt hash
+func CheckPassword(hash, password string) bool {
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	}
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false
+	}
+	var memory, iterations uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false
+	}
+	other := argon2.IDKey([]byte(password), salt, iterations, memory, threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1
+}
+
+// helper function to generate random token and its hash
+func newToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := hex.EncodeToString(buf)
+	return token, tokenHash(token), nil
+}
+
+// helper function to generate random positive user id
+func newUid() (int, error) {
+	buf := make([]byte, 4)
+	for {
+		if _, err := rand.Read(buf); err != nil {
+			return 0, err
+		}
+		if uid := int(binary.BigEndian.Uint32(buf) & 0x7fffffff); uid > 0 {
+			return uid, nil
+		}
+	}
+}
+
+// helper function to get hash of given token
+func tokenHash(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// helper function to compare token with its stored hash
+func checkToken(hash, token string) bool {
+	if hash == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(tokenHash(token))) == 1
+}
+
+// RegisterUser creates new user account and returns email verification token,
+// user id is random and stores report collisions via ErrUserIDExists
+func RegisterUser(store UserStore, login, email, password string) (User, string, error) {
+	if _, err := store.GetUser(login); err == nil {
+		return User{}, "", ErrUserExists
+	} else if !errors.Is(err, ErrUserNotFound) {
+		return User{}, "", err
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return User{}, "", err
+	}
+	token, thash, err := newToken()
+	if err != nil {
+		return User{}, "", err
+	}
+	user := User{
+		Login:       login,
+		Email:       email,
+		Password:    hash,
+		VerifyToken: thash,
+		Created:     time.Now().Unix(),
+	}
+	for attempt := 0; ; attempt++ {
+		user.Uid, err = newUid()
+		if err != nil {
+			return User{}, "", err
+		}
+		err = store.AddUser(user)
+		if errors.Is(err, ErrUserIDExists) && attempt < 3 {
+			continue
+		}
+		if err != nil {
+			return User{}, "", err
+		}
+		return user, token, nil
+	}
+}
+
+// VerifyEmail verifies email of given user with verification token
+func VerifyEmail(store UserStore, login, token string) error {
+	user, err := store.GetUser(login)
+	if err != nil {
+		return err
+	}
+	if !checkToken(user.VerifyToken, token) {
+		return ErrInvalidToken
+	}
+	user.Verified = true
+	user.VerifyToken = ""
+	return store.UpdateUser(user)
+}
+
+// RequestPasswordReset creates password reset token for given user
+func RequestPasswordReset(store UserStore, login string) (string, error) {
+	user, err := store.GetUser(login)
+	if err != nil {
+		return "", err
+	}
+	token, thash, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	user.ResetToken = thash
+	user.ResetExpires = time.Now().Add(ResetTokenExpires).Unix()
+	return token, store.UpdateUser(user)
+}
+
+// ResetPassword sets new password of given user using password reset token
+func ResetPassword(store UserStore, login, token, password string) error {
+	user, err := store.GetUser(login)
+	if err != nil {
+		return err
+	}
+	if !checkToken(user.ResetToken, token) || user.ResetExpires < time.Now().Unix() {
+		return ErrInvalidToken
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.Password = hash
+	user.PassChanged = time.Now().Unix()
+	user.ResetToken = ""
+	user.ResetExpires = 0
+	if err := store.UpdateUser(user); err != nil {
+		return err
+	}
+	return store.ResetFailures(login)
+}
+
+// helper function to record failed login attempt and lock the account
+// after MaxLoginFailures consecutive failures
+func loginFailure(store UserStore, login string) error {
+	lockUntil := time.Now().Add(LockoutPeriod).Unix()
+	return store.RecordFailure(login, MaxLoginFailures, lockUntil)
+}
+
+// helper function to reset failed login attempts after successful login
+func loginSuccess(store UserStore, user *User) error {
+	if user.Failures == 0 && user.LockedUntil == 0 {
+		return nil
+	}
+	user.Failures = 0
+	user.LockedUntil = 0
+	return store.ResetFailures(user.Login)
+}
+
+// dummy password hash checked for unknown logins
+var (
+	dummyHash     string
+	dummyHashOnce sync.Once
+)
+
+// helper function to check password of unknown login, it spends the same
+// time as check of existing user password
+func dummyCheckPassword(password string) {
+	dummyHashOnce.Do(func() {
+		token, _, _ := newToken()
+		dummyHash, _ = HashPassword(token)
+	})
+	CheckPassword(dummyHash, password)
+}
+
+// Authenticate checks user credentials and applies account lockout policy.
+// It returns ErrMFARequired error for users with enabled TOTP, such users
+// are authenticated by AuthenticateMFA. On failure it returns empty user
+// to not reveal whether given login exists.
+func Authenticate(store UserStore, login, password string) (User, error) {
+	user, err := store.GetUser(login)
+	if errors.Is(err, ErrUserNotFound) {
+		dummyCheckPassword(password)
+		return User{}, ErrInvalidPassword
+	} else if err != nil {
+		return User{}, err
+	}
+	if user.Locked() {
+		return User{}, ErrAccountLocked
+	}
+	if !CheckPassword(user.Password, password) {
+		if err := loginFailure(store, login); err != nil {
+			return User{}, err
+		}
+		return User{}, ErrInvalidPassword
+	}
+	if !user.Verified {
+		return User{}, ErrNotVerified
+	}
+	// failures are reset only after verification of second factor
+	if user.TOTPEnabled {
+		return User{}, ErrMFARequired
+	}
+	if err := loginSuccess(store, &user); err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+// AuthenticateMFA checks user credentials and TOTP or recovery code of users
+// with enabled TOTP, it returns authentication methods to be used in amr
+// claim, e.g. UserToken(user, clientId, expires, amr...)
+func AuthenticateMFA(store UserStore, login, password, code string) (User, []string, error) {
+	amr := []string{AMRPassword}
+	user, err := Authenticate(store, login, password)
+	if !errors.Is(err, ErrMFARequired) {
+		return user, amr, err
+	}
+	if isTOTPCode(code) {
+		err = VerifyTOTP(store, login, code)
+	} else {
+		err = UseRecoveryCode(store, login, code)
+	}
+	if err != nil {
+		return user, amr, err
+	}
+	amr = append(amr, AMROTP)
+	user, err = store.GetUser(login)
+	return user, amr, err
+}
+
+// UserClaims creates token claims for given user, optional authentication
+// methods (e.g. AMRPassword, AMROTP) are stored in amr claim
+func UserClaims(user User, expires int64, amr ...string) *Claims {
+	now := time.Now()
+	var methods []string
+	for _, m := range amr {
+		if m != AMRMFA && !utils.InList(m, methods) {
+			methods = append(methods, m)
+		}
+	}
+	amr = methods
+	if len(amr) > 1 {
+		amr = append(amr, AMRMFA)
+	}
+	return &Claims{
+		Login: user.Login,
+		AMR:   amr,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.Login,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Duration(expires) * time.Second)),
+		},
+	}
+}
+
+// UserToken issues access token for given user signed with client id,
+// it can be validated by Token.Validate
+func UserToken(user User, clientId string, expires int64, amr ...string) (Token, error) {
+	return NewToken(UserClaims(user, expires, amr...), clientId, expires)
+}
+
+// NewToken issues access token for given claims signed with client id
+func NewToken(claims *Claims, clientId string, expires int64) (Token, error) {
+	tkn := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	accessToken, err := tkn.SignedString([]byte(clientId))
+	if err != nil {
+		return Token{}, err
+	}
+	token := Token{
+		AccessToken: accessToken,
+		Expires:     int(expires),
+		TokenType:   "bearer",
+	}
+	return token, nil
+}
+
+// UserResponse creates response for given user and error, failure
+// responses do not contain user id
+func UserResponse(user User, err error) Response {
+	if err != nil {
+		return Response{Status: "fail", Error: err.Error()}
+	}
+	return Response{Status: "ok", Uid: user.Uid}
+}
@@ -0,0 +1,248 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+// memoryStore implements UserStore interface for unit tests
+type memoryStore map[string]User
+
+func (m memoryStore) GetUser(login string) (User, error) {
+	if user, ok := m[login]; ok {
+		return user, nil
+	}
+	return User{}, ErrUserNotFound
+}
+
+func (m memoryStore) AddUser(user User) error {
+	m[user.Login] = user
+	return nil
+}
+
+func (m memoryStore) UpdateUser(user User) error {
+	old, ok := m[user.Login]
+	if !ok {
+		return ErrUserNotFound
+	}
+	old.Email = user.Email
+	old.Password = user.Password
+	old.PassChanged = user.PassChanged
+	old.Verified = user.Verified
+	old.VerifyToken = user.VerifyToken
+	old.ResetToken = user.ResetToken
+	old.ResetExpires = user.ResetExpires
+	m[user.Login] = old
+	return nil
+}
+
+func (m memoryStore) UpdateTOTP(user User) error {
+	old, ok := m[user.Login]
+	if !ok {
+		return ErrUserNotFound
+	}
+	old.TOTPSecret = user.TOTPSecret
+	old.TOTPEnabled = user.TOTPEnabled
+	old.RecoveryCodes = user.RecoveryCodes
+	m[user.Login] = old
+	return nil
+}
+
+func (m memoryStore) UseTOTPStep(login string, step int64) (bool, error) {
+	user, ok := m[login]
+	if !ok {
+		return false, ErrUserNotFound
+	}
+	if step <= user.TOTPLastStep {
+		return false, nil
+	}
+	user.TOTPLastStep = step
+	m[login] = user
+	return true, nil
+}
+
+func (m memoryStore) RemoveRecoveryCode(login, hash string) (bool, error) {
+	user, ok := m[login]
+	if !ok {
+		return false, ErrUserNotFound
+	}
+	var codes []string
+	for _, h := range user.RecoveryCodes {
+		if h != hash {
+			codes = append(codes, h)
+		}
+	}
+	if len(codes) == len(user.RecoveryCodes) {
+		return false, nil
+	}
+	user.RecoveryCodes = codes
+	m[login] = user
+	return true, nil
+}
+
+func (m memoryStore) RecordFailure(login string, max int, lockUntil int64) error {
+	user, ok := m[login]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.Failures += 1
+	if max > 0 && user.Failures >= max {
+		user.Failures = 0
+		user.LockedUntil = lockUntil
+	}
+	m[login] = user
+	return nil
+}
+
+func (m memoryStore) ResetFailures(login string) error {
+	user, ok := m[login]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.Failures = 0
+	user.LockedUntil = 0
+	m[login] = user
+	return nil
+}
+
+// TestPasswordHash
+func TestPasswordHash(t *testing.T) {
+	for _, alg := range []string{"argon2id", "bcrypt"} {
+		PasswordAlgorithm = alg
+		hash, err := HashPassword("secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !CheckPassword(hash, "secret") {
+			t.Errorf("%s hash does not match its password", alg)
+		}
+		if CheckPassword(hash, "other") {
+			t.Errorf("%s hash matches wrong password", alg)
+		}
+	}
+	PasswordAlgorithm = "argon2id"
+}
+
+// TestUserLifecycle
+func TestUserLifecycle(t *testing.T) {
+	store := make(memoryStore)
+	_, vtoken, err := RegisterUser(store, "alice", "alice@example.com", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := RegisterUser(store, "alice", "", "secret"); !errors.Is(err, ErrUserExists) {
+		t.Errorf("duplicate registration returns %v", err)
+	}
+	if _, err := Authenticate(store, "alice", "secret"); !errors.Is(err, ErrNotVerified) {
+		t.Errorf("unverified login returns %v", err)
+	}
+	if err := VerifyEmail(store, "alice", "wrong"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("wrong verification token returns %v", err)
+	}
+	if err := VerifyEmail(store, "alice", vtoken); err != nil {
+		t.Fatal(err)
+	}
+	user, err := Authenticate(store, "alice", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := UserToken(user, "client", 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := token.Claims("client")
+	if err != nil || claims.Login != "alice" {
+		t.Errorf("invalid user token claims %+v error %v", claims, err)
+	}
+
+	// lock account with wrong passwords and unlock it via password reset
+	for i := 0; i < MaxLoginFailures; i++ {
+		Authenticate(store, "alice", "wrong")
+	}
+	if _, err := Authenticate(store, "alice", "secret"); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("locked account login returns %v", err)
+	}
+	rtoken, err := RequestPasswordReset(store, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ResetPassword(store, "alice", rtoken, "newsecret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Authenticate(store, "alice", "newsecret"); err != nil {
+		t.Errorf("login after password reset returns %v", err)
+	}
+}
+
+// TestLockoutStaleUpdate
+func TestLockoutStaleUpdate(t *testing.T) {
+	store := make(memoryStore)
+	_, vtoken, err := RegisterUser(store, "alice", "alice@example.com", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyEmail(store, "alice", vtoken); err != nil {
+		t.Fatal(err)
+	}
+	// update of user loaded before failed logins does not reset them
+	stale := store["alice"]
+	for i := 0; i < MaxLoginFailures-1; i++ {
+		Authenticate(store, "alice", "wrong")
+	}
+	stale.ResetToken = "token"
+	if err := store.UpdateUser(stale); err != nil {
+		t.Fatal(err)
+	}
+	Authenticate(store, "alice", "wrong")
+	if _, err := Authenticate(store, "alice", "secret"); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("stale update resets failed logins, login returns %v", err)
+	}
+}
+
+// uidStore rejects first user ids to simulate collisions
+type uidStore struct {
+	memoryStore
+	collisions int
+}
+
+func (s *uidStore) AddUser(user User) error {
+	if s.collisions > 0 {
+		s.collisions--
+		return ErrUserIDExists
+	}
+	return s.memoryStore.AddUser(user)
+}
+
+// TestRegisterUserUid
+func TestRegisterUserUid(t *testing.T) {
+	store := &uidStore{memoryStore: make(memoryStore), collisions: 2}
+	user, _, err := RegisterUser(store, "alice", "alice@example.com", "secret")
+	if err != nil || user.Uid <= 0 || store.memoryStore["alice"].Uid != user.Uid {
+		t.Errorf("wrong registration after uid collisions %+v error %v", user, err)
+	}
+	store = &uidStore{memoryStore: make(memoryStore), collisions: 10}
+	if _, _, err := RegisterUser(store, "bob", "", "secret"); !errors.Is(err, ErrUserIDExists) {
+		t.Errorf("persistent uid collisions return %v", err)
+	}
+}
+
+// TestAuthenticateFailure
+func TestAuthenticateFailure(t *testing.T) {
+	store := make(memoryStore)
+	user, vtoken, err := RegisterUser(store, "alice", "alice@example.com", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyEmail(store, "alice", vtoken); err != nil {
+		t.Fatal(err)
+	}
+	for _, login := range []string{"alice", "unknown"} {
+		user, err := Authenticate(store, login, "wrong")
+		if !errors.Is(err, ErrInvalidPassword) || user.Uid != 0 || user.Login != "" {
+			t.Errorf("wrong password of %s returns user %+v error %v", login, user, err)
+		}
+	}
+	if resp := UserResponse(user, ErrInvalidPassword); resp.Uid != 0 {
+		t.Errorf("failure response contains user id %+v", resp)
+	}
+}
@@ -0,0 +1,111 @@
+package config
+
+// bootstrap of OreCast services
+//
+// Services parse their command line and load configuration via Bootstrap,
+// e.g.
+//
+//	var port int
+//	svc, err := config.Bootstrap(flag.CommandLine, os.Args[1:], config.Options{
+//		Name:     "discovery",
+//		Sections: []string{config.DiscoverySection},
+//		Commands: []string{"run", "migrate"},
+//		Flags: func(fs *flag.FlagSet) {
+//			fs.IntVar(&port, "port", 0, "override server port")
+//		},
+//	})
+//	if errors.Is(err, config.ErrVersion) {
+//		os.Exit(0)
+//	}
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/OreCast/common/utils"
+)
+
+// ErrVersion is returned by Bootstrap when version information was requested
+// and printed, services should exit without error
+var ErrVersion = errors.New("version information is requested")
+
+// Options represents bootstrap options of a service
+type Options struct {
+	Name     string                 // name of the service
+	Sections []string               // sections to validate, none by default
+	Commands []string               // sub-commands of the service, the first one is default
+	Flags    func(fs *flag.FlagSet) // registers service specific flags
+}
+
+// Service represents bootstrapped service
+type Service struct {
+	Name    string         // name of the service
+	Build   BuildInfo      // version information of the service
+	Config  *OreCastConfig // configuration loaded at startup, see Current for reloaded one
+	Loader  *Loader        // loader of configuration, used by Reload
+	Command string         // selected sub-command
+	Args    []string       // arguments of sub-command
+	Flags   *flag.FlagSet  // parsed flag set
+}
+
+// FileList holds list of files set via repeated command line flag, it
+// implements flag.Value interface
+type FileList []string
+
+// String implements flag.Value interface
+func (f *FileList) String() string {
+	return strings.Join(*f, ",")
+}
+
+// Set implements flag.Value interface
+func (f *FileList) Set(fname string) error {
+	*f = append(*f, fname)
+	return nil
+}
+
+// Bootstrap registers common flags on given flag set, parses given arguments,
+// selects sub-command and loads configuration. It returns ErrVersion when
+// -version flag is provided and errors of flags parsing and configuration
+// loading.
+func Bootstrap(fs *flag.FlagSet, args []string, opts Options) (*Service, error) {
+	var version bool
+	fs.BoolVar(&version, "version", false, "Show version")
+	loader := &Loader{Sections: opts.Sections, Overrides: make(Overrides)}
+	fs.StringVar(&loader.File, "config", "", "server config file")
+	fs.Var(loader.Overrides, "set", "override configuration value, e.g. -set discovery.port=8320")
+	fs.Var((*FileList)(&loader.Overlays), "overlay", "overlay config file, can be repeated")
+	fs.StringVar(&loader.Env, "env", "", "environment overlay, "+EnvVar+" by default")
+	if opts.Flags != nil {
+		opts.Flags(fs)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	name := opts.Name
+	if name == "" {
+		name = "server"
+	}
+	if version {
+		fmt.Fprintf(fs.Output(), "%s version: %s\n", name, Build())
+		return nil, ErrVersion
+	}
+	svc := &Service{Name: name, Build: Build(), Loader: loader, Flags: fs, Args: fs.Args()}
+	if len(opts.Commands) > 0 {
+		svc.Command = opts.Commands[0]
+		if len(svc.Args) > 0 {
+			if !utils.InList(svc.Args[0], opts.Commands) {
+				return nil, fmt.Errorf("unknown command %q, expect one of %s",
+					svc.Args[0], strings.Join(opts.Commands, ", "))
+			}
+			svc.Command, svc.Args = svc.Args[0], svc.Args[1:]
+		}
+	}
+	config, err := loader.Load()
+	if err != nil {
+		return nil, err
+	}
+	svc.Config = config
+	return svc, nil
+}
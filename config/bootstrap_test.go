@@ -0,0 +1,75 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestBootstrap
+func TestBootstrap(t *testing.T) {
+	dir := t.TempDir()
+	cfile := filepath.Join(dir, "orecast.yaml")
+	writeFile(t, cfile, `
+discovery:
+  port: 8320
+  dbname: OreCast
+  dburi: mongodb://localhost:8230
+`)
+	opts := Options{
+		Name:     "discovery",
+		Sections: []string{DiscoverySection},
+		Commands: []string{"run", "migrate"},
+	}
+	var dryRun bool
+	opts.Flags = func(fs *flag.FlagSet) {
+		fs.BoolVar(&dryRun, "dry-run", false, "do not modify database")
+	}
+	fs := flag.NewFlagSet("discovery", flag.ContinueOnError)
+	args := []string{"-config", cfile, "-set", "discovery.port=8321", "-dry-run", "migrate", "v2"}
+	svc, err := Bootstrap(fs, args, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svc.Command != "migrate" || len(svc.Args) != 1 || svc.Args[0] != "v2" || !dryRun {
+		t.Errorf("wrong command line parsing %+v dry-run=%v", svc, dryRun)
+	}
+	if svc.Config.Discovery.Port != 8321 || Current() != svc.Config {
+		t.Errorf("wrong configuration %+v", svc.Config.Discovery)
+	}
+
+	// default command
+	fs = flag.NewFlagSet("discovery", flag.ContinueOnError)
+	if svc, err = Bootstrap(fs, []string{"-config", cfile}, opts); err != nil || svc.Command != "run" {
+		t.Errorf("wrong default command, error %v", err)
+	}
+
+	// version, unknown command and invalid configuration are reported as errors
+	var out bytes.Buffer
+	fs = flag.NewFlagSet("discovery", flag.ContinueOnError)
+	fs.SetOutput(&out)
+	if _, err = Bootstrap(fs, []string{"-version"}, opts); !errors.Is(err, ErrVersion) {
+		t.Errorf("wrong version error %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "discovery version:") {
+		t.Errorf("wrong version output %q", out.String())
+	}
+	fs = flag.NewFlagSet("discovery", flag.ContinueOnError)
+	if _, err = Bootstrap(fs, []string{"-config", cfile, "bla"}, opts); err == nil {
+		t.Error("unknown command is accepted")
+	}
+	fs = flag.NewFlagSet("discovery", flag.ContinueOnError)
+	fs.SetOutput(&out)
+	if _, err = Bootstrap(fs, []string{"-unknown"}, opts); err == nil {
+		t.Error("unknown flag is accepted")
+	}
+	fs = flag.NewFlagSet("discovery", flag.ContinueOnError)
+	_, err = Bootstrap(fs, []string{"-config", cfile, "-set", "discovery.dburi=localhost"}, opts)
+	var verr ValidationError
+	if !errors.As(err, &verr) {
+		t.Errorf("invalid configuration is not reported, error %v", err)
+	}
+}
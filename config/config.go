@@ -7,154 +7,189 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"runtime"
-	"time"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 )
 
 // OAuthRecord defines OAuth provider's credentials
 type OAuthRecord struct {
-	Provider     string `mapstructure:"provider"`      // name of the provider
-	ClientID     string `mapstructure:"client_id"`     // client id
-	ClientSecret string `mapstructure:"client_secret"` // client secret
+	Provider     string `mapstructure:"provider" doc:"name of OAuth provider, e.g. github"`
+	ClientID     string `mapstructure:"client_id" doc:"client id of OAuth application"`
+	ClientSecret string `mapstructure:"client_secret" doc:"client secret of OAuth application"`
 }
 
 // WebServer represents common web server configuration
 type WebServer struct {
-	Base      string `mapstructure:"base"`       // base URL
-	LogFile   string `mapstructure:"log_file"`   // server log file
-	Port      int    `mapstructure:"port"`       // server port number
-	Verbose   int    `mapstructure:"verbose"`    // verbose output
-	StaticDir string `mapstructure:"static_dir"` // speficy static dir location
+	Base      string `mapstructure:"base" doc:"base path of server end-points, e.g. /discovery"`
+	LogFile   string `mapstructure:"log_file" doc:"server log file, logs are written to stdout if not set"`
+	Port      int    `mapstructure:"port" doc:"server port number"`
+	Verbose   int    `mapstructure:"verbose" doc:"verbosity level of server output"`
+	StaticDir string `mapstructure:"static_dir" doc:"location of static files"`
 
 	// middleware server parts
-	LimiterPeriod string `mapstructure:"rate"` // limiter rate value
+	LimiterPeriod string `mapstructure:"rate" doc:"rate limiter value, e.g. 100-S"`
 
 	// proxy parts
-	XForwardedHost      string `mapstructure:"X-Forwarded-Host"`       // X-Forwarded-Host field of HTTP request
-	XContentTypeOptions string `mapstructure:"X-Content-Type-Options"` // X-Content-Type-Options option
+	XForwardedHost      string   `mapstructure:"X-Forwarded-Host" doc:"X-Forwarded-Host field of HTTP request"`
+	XContentTypeOptions string   `mapstructure:"X-Content-Type-Options" doc:"X-Content-Type-Options HTTP header value"`
+	TrustedProxies      []string `mapstructure:"trusted_proxies" doc:"proxy addresses or CIDRs trusted to set X-Forwarded-For, none by default"`
 
 	// TLS server parts
-	RootCAs     string   `mapstructure:"rootCAs"`      // server Root CAs path
-	ServerCrt   string   `mapstructure:"server_cert"`  // server certificate
-	ServerKey   string   `mapstructure:"server_key"`   // server certificate
-	DomainNames []string `mapstructure:"domain_names"` // LetsEncrypt domain names
+	RootCAs     string   `mapstructure:"rootCAs" doc:"location of root CA certificates"`
+	ServerCrt   string   `mapstructure:"server_cert" doc:"server certificate file"`
+	ServerKey   string   `mapstructure:"server_key" doc:"server certificate key file"`
+	DomainNames []string `mapstructure:"domain_names" doc:"domain names of LetsEncrypt certificates"`
 }
 
 // Frontend stores frontend configuration parameters
 type Frontend struct {
-	WebServer
+	WebServer `mapstructure:",squash"`
 
 	// OAuth parts
-	OAuth []OAuthRecord `mapstructure:"oauth"` // oauth configurations
+	OAuth []OAuthRecord `mapstructure:"oauth" doc:"OAuth providers"`
 
 	// captcha parts
-	CaptchaSecretKey string `mapstructure:"captchaSecretKey"` // re-captcha secret key
-	CaptchaPublicKey string `mapstructure:"captchaPublicKey"` // re-captcha public key
-	CaptchaVerifyUrl string `mapstructure:"captchaVerifyUrl"` // re-captcha verify url
+	CaptchaSecretKey string `mapstructure:"captchaSecretKey" doc:"re-captcha secret key"`
+	CaptchaPublicKey string `mapstructure:"captchaPublicKey" doc:"re-captcha public key"`
+	CaptchaVerifyUrl string `mapstructure:"captchaVerifyUrl" doc:"re-captcha verify URL"`
 
 	// cookies parts
-	UserCookieExpires int64 `mapstructure:"user_cookie_expires"` // expiration of user cookie
+	UserCookieExpires int64 `mapstructure:"user_cookie_expires" doc:"expiration of user cookie in seconds"`
 }
 
 // Encryption represents encryption configuration parameters
 type Encryption struct {
-	Secret string `mapstructure:"secret"`
-	Cipher string `mapstructure:"cipher"`
+	Secret string `mapstructure:"secret" doc:"encryption secret"`
+	Cipher string `mapstructure:"cipher" default:"aes" doc:"encryption cipher, aes or nacl"`
 }
 
 // MongoDB represents MongoDB parameters
 type MongoDB struct {
-	DBName string `mapstructure:"dbname"` // database name
-	DBColl string `mapstructure:"dbcoll"` // database collection
-	DBUri  string `mapstructure:"dburi"`  // database URI
+	DBName string `mapstructure:"dbname" doc:"MongoDB database name"`
+	DBColl string `mapstructure:"dbcoll" doc:"MongoDB collection name"`
+	DBUri  string `mapstructure:"dburi" doc:"MongoDB URI, e.g. mongodb://localhost:8230"`
+
+	AppName        string `mapstructure:"app_name" doc:"application name reported to MongoDB"`
+	PoolSize       int    `mapstructure:"pool_size" doc:"maximum number of MongoDB connections"`
+	ConnectTimeout int    `mapstructure:"connect_timeout" doc:"MongoDB connect timeout in seconds"`
+	Timeout        int    `mapstructure:"timeout" doc:"MongoDB socket timeout in seconds"`
+	ReadPreference string `mapstructure:"read_preference" doc:"MongoDB read preference, e.g. primary or secondaryPreferred"`
+	WriteConcern   string `mapstructure:"write_concern" doc:"MongoDB write concern, majority or number of nodes"`
 }
 
 // Discovery represents discovery service configuration
 type Discovery struct {
-	WebServer
-	MongoDB
-	Encryption
+	WebServer  `mapstructure:",squash"`
+	MongoDB    `mapstructure:",squash"`
+	Encryption `mapstructure:",squash"`
 }
 
 // MetaData represents metadata service configuration
 type MetaData struct {
-	WebServer
-	MongoDB
+	WebServer `mapstructure:",squash"`
+	MongoDB   `mapstructure:",squash"`
 }
 
 // DataManagement represents data-management service configuration
 type DataManagement struct {
-	WebServer
+	WebServer `mapstructure:",squash"`
 }
 
 // DataBookkeeping represents data-bookkeeping service configuration
 type DataBookkeeping struct {
-	WebServer
+	WebServer `mapstructure:",squash"`
 
-	DBFile             string `mapstructure:"dbfile"`               // dbs db file with secrets
-	MaxDBConnections   int    `mapstructure:"max_db_connections"`   // maximum number of DB connections
-	MaxIdleConnections int    `mapstructure:"max_idle_connections"` // maximum number of idle connections
+	DBFile             string `mapstructure:"dbfile" doc:"file with database connection parameters"`
+	MaxDBConnections   int    `mapstructure:"max_db_connections" default:"100" doc:"maximum number of database connections"`
+	MaxIdleConnections int    `mapstructure:"max_idle_connections" default:"100" doc:"maximum number of idle database connections"`
 }
 
 // Authz represents authz service configuration
 type Authz struct {
-	WebServer
-	Encryption
+	WebServer  `mapstructure:",squash"`
+	Encryption `mapstructure:",squash"`
 
-	DBUri        string `mapstructure:"dburi"` // database URI
-	ClientId     string `mapstructure:"client_id"`
-	ClientSecret string `mapstructure:"client_secret"`
-	Domain       string `mapstructure:"domain"`
-	TokenExpires int64  `mapstructure:token_expires` // expiration of token
+	DBUri        string `mapstructure:"dburi" doc:"MongoDB URI of authz database"`
+	ClientId     string `mapstructure:"client_id" doc:"client id of authz service"`
+	ClientSecret string `mapstructure:"client_secret" doc:"client secret of authz service"`
+	Domain       string `mapstructure:"domain" doc:"domain of authz service"`
+	TokenExpires int64  `mapstructure:"token_expires" default:"3600" doc:"expiration of tokens in seconds"`
 }
 
 // Services represents orecast services
 type Services struct {
-	FrontendURL        string `mapstructure:"frontend_url"`
-	DiscoveryURL       string `mapstructure:"discovery_url"`
-	MetaDataURL        string `mapstructure:"metadata_url"`
-	DataManagementURL  string `mapstructure:"datamanagement_url"`
-	DataBookkeepingURL string `mapstructure:"databookkeeping_url"`
-	AuthzURL           string `mapstructure:"authz_url"`
+	FrontendURL        string `mapstructure:"frontend_url" doc:"URL of frontend service"`
+	DiscoveryURL       string `mapstructure:"discovery_url" doc:"URL of discovery service"`
+	MetaDataURL        string `mapstructure:"metadata_url" doc:"URL of metadata service"`
+	DataManagementURL  string `mapstructure:"datamanagement_url" doc:"URL of data-management service"`
+	DataBookkeepingURL string `mapstructure:"databookkeeping_url" doc:"URL of data-bookkeeping service"`
+	AuthzURL           string `mapstructure:"authz_url" doc:"URL of authz service"`
+}
+
+// Feature represents feature flag, it is enabled for everyone, for listed
+// users and sites or for given percentage of users or sites
+type Feature struct {
+	Enabled    bool     `mapstructure:"enabled" doc:"feature is enabled for everyone"`
+	Percentage int      `mapstructure:"percentage" doc:"percentage of users or sites with enabled feature"`
+	Key        string   `mapstructure:"key" doc:"key of percentage rollout, user (default) or site"`
+	Users      []string `mapstructure:"users" doc:"users with enabled feature"`
+	Sites      []string `mapstructure:"sites" doc:"sites with enabled feature"`
 }
 
-// OreCastConfig represents orecast configuration
+// OreCastConfig represents orecast configuration, every service has its own
+// section which inherits shared parameters from common section, e.g.
+//
+//	common:
+//	  dburi: mongodb://localhost:8230
+//	discovery:
+//	  port: 8320
+//	  dbname: OreCast
+//	metadata:
+//	  port: 8300
+//	  dbname: OreCast
 type OreCastConfig struct {
-	Frontend
-	Discovery
-	MetaData
-	DataManagement
-	DataBookkeeping
-	Authz
-	Services
-	Encryption
-}
-
-func ParseConfig(cfile string) (OreCastConfig, error) {
-	var config OreCastConfig
+	Frontend        `mapstructure:"frontend" doc:"frontend service"`
+	Discovery       `mapstructure:"discovery" doc:"discovery service"`
+	MetaData        `mapstructure:"metadata" doc:"metadata service"`
+	DataManagement  `mapstructure:"datamanagement" doc:"data-management service"`
+	DataBookkeeping `mapstructure:"databookkeeping" doc:"data-bookkeeping service"`
+	Authz           `mapstructure:"authz" doc:"authz service"`
+	Services        `mapstructure:"services" doc:"URLs of OreCast services"`
+	Encryption      `mapstructure:"encryption" doc:"encryption of configuration values"`
+
+	Features map[string]Feature `mapstructure:"features" doc:"feature flags"`
+}
+
+// ParseConfig parses given configuration file and validates given sections
+// of the configuration
+func ParseConfig(cfile string, sections ...string) (OreCastConfig, error) {
+	loader := &Loader{File: cfile, Sections: sections}
+	return loader.Parse()
+}
+
+// helper function to read configuration file into settings map,
+// it returns settings and name of used file
+func readFile(cfile string) (map[string]interface{}, string, error) {
+	v := viper.New()
 	if cfile != "" {
 		// Use config file from the flag.
-		viper.SetConfigFile(cfile)
+		v.SetConfigFile(cfile)
 	} else {
 		// Find home directory.
 		home, err := os.UserHomeDir()
 		if err != nil {
-			fmt.Println("ERROR", err)
-			os.Exit(1)
+			return nil, "", err
 		}
 		// Search config in home directory with name ".orecast" (without extension).
-		viper.AddConfigPath(home)
-		viper.SetConfigType("yaml")
-		viper.SetConfigName(".orecast")
+		v.AddConfigPath(home)
+		v.SetConfigType("yaml")
+		v.SetConfigName(".orecast")
 		// setup cfile to $HOME/.orecast.yaml
 		cfile = filepath.Join(home, ".orecast.yaml")
 	}
 
-	viper.AutomaticEnv()
-
-	if err := viper.ReadInConfig(); err != nil {
+	if err := v.ReadInConfig(); err != nil {
 		msg := err.Error()
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
 			// Config file not found; ignore error if desired
@@ -163,12 +198,25 @@ func ParseConfig(cfile string) (OreCastConfig, error) {
 			// Config file was found but another error was produced
 			msg = fmt.Sprintf("unable to parse %s, error %v", cfile, err)
 		}
-		return config, errors.New(msg)
+		return nil, cfile, errors.New(msg)
 	}
-	if err := viper.Unmarshal(&config); err != nil {
-		return config, err
+	return v.AllSettings(), v.ConfigFileUsed(), nil
+}
+
+// helper function to decode settings map into configuration
+func decodeSettings(settings map[string]interface{}, config *OreCastConfig) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+		WeaklyTypedInput: true,
+		Result:           config,
+	})
+	if err != nil {
+		return err
 	}
-	return config, nil
+	return decoder.Decode(settings)
 }
 
 /*
@@ -185,29 +233,26 @@ func main() {
 }
 */
 
-// Config represnets orecast configuration
+// Config represnets orecast configuration of Load call, it is not changed by
+// reloads since it can not be swapped atomically
+//
+// Deprecated: use Current instead, it returns reloaded configuration.
 var Config *OreCastConfig
 
+// Info returns version information of the service
 func Info() string {
-	goVersion := runtime.Version()
-	tstamp := time.Now()
-	return fmt.Sprintf("git={{VERSION}} go=%s date=%s", goVersion, tstamp)
-}
-
-func Init() {
-	var version bool
-	flag.BoolVar(&version, "version", false, "Show version")
-	var config string
-	flag.StringVar(&config, "config", "", "server config file")
-	flag.Parse()
-	if version {
-		fmt.Println("server version:", Info())
-		return
-	}
+	return Build().String()
+}
+
+// Init bootstraps service with global command line flags and validates
+// given sections of its configuration, it exits on -version flag and on
+// errors, see Bootstrap for reusable API
+func Init(sections ...string) {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	oConfig, err := ParseConfig(config)
-	if err != nil {
-		log.Fatal("ERROR", err)
+	if _, err := Bootstrap(flag.CommandLine, os.Args[1:], Options{Sections: sections}); err != nil {
+		if errors.Is(err, ErrVersion) {
+			os.Exit(0)
+		}
+		log.Fatal("ERROR ", err)
 	}
-	Config = &oConfig
 }
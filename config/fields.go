@@ -0,0 +1,170 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Field describes single parameter of OreCastConfig
+type Field struct {
+	Key   string              // key path, e.g. authz.client_id
+	Field reflect.StructField // struct field of the parameter
+}
+
+// Fields returns all parameters of OreCastConfig in declaration order
+func Fields() []Field {
+	return structFields("", reflect.TypeOf(OreCastConfig{}))
+}
+
+// helper function to return mapstructure name of struct field and its squash flag
+func fieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("mapstructure")
+	parts := strings.Split(tag, ",")
+	squash := false
+	for _, opt := range parts[1:] {
+		if opt == "squash" {
+			squash = true
+		}
+	}
+	name := parts[0]
+	if name == "" && !squash {
+		name = field.Name
+	}
+	return name, squash
+}
+
+// helper function to collect parameters of given struct type
+func structFields(prefix string, rtype reflect.Type) []Field {
+	var out []Field
+	for i := 0; i < rtype.NumField(); i++ {
+		field := rtype.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, squash := fieldName(field)
+		if squash {
+			out = append(out, structFields(prefix, field.Type)...)
+			continue
+		}
+		key := joinKey(prefix, name)
+		if field.Type.Kind() == reflect.Struct {
+			out = append(out, structFields(key, field.Type)...)
+			continue
+		}
+		out = append(out, Field{Key: key, Field: field})
+	}
+	return out
+}
+
+// Settings returns configuration as settings map keyed by mapstructure
+// names, e.g. settings["authz"]["client_id"]
+func (c *OreCastConfig) Settings() map[string]interface{} {
+	return structSettings(reflect.ValueOf(c).Elem())
+}
+
+// helper function to convert struct value into settings map
+func structSettings(val reflect.Value) map[string]interface{} {
+	out := make(map[string]interface{})
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, squash := fieldName(field)
+		if squash {
+			for key, item := range structSettings(val.Field(i)) {
+				out[key] = item
+			}
+			continue
+		}
+		out[name] = settingsValue(val.Field(i))
+	}
+	return out
+}
+
+// helper function to convert value into settings value
+func settingsValue(val reflect.Value) interface{} {
+	switch val.Kind() {
+	case reflect.Struct:
+		return structSettings(val)
+	case reflect.Slice:
+		if val.Type().Elem().Kind() != reflect.Struct {
+			break
+		}
+		var out []interface{}
+		for i := 0; i < val.Len(); i++ {
+			out = append(out, structSettings(val.Index(i)))
+		}
+		return out
+	}
+	return val.Interface()
+}
+
+// helper function to look-up parameter by its key path
+func lookupField(key string) (Field, bool) {
+	for _, f := range Fields() {
+		if strings.EqualFold(f.Key, key) {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// helper function to get value of settings by key path
+func getKey(settings map[string]interface{}, key string) (interface{}, bool) {
+	parts := strings.Split(strings.ToLower(key), ".")
+	m := settings
+	for i, part := range parts {
+		val, ok := m[part]
+		if !ok {
+			return nil, false
+		}
+		if i == len(parts)-1 {
+			return val, true
+		}
+		if m, ok = toMap(val); !ok {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// helper function to set value of settings by key path
+func setKey(settings map[string]interface{}, key string, val interface{}) {
+	parts := strings.Split(strings.ToLower(key), ".")
+	m := settings
+	for _, part := range parts[:len(parts)-1] {
+		sub, ok := toMap(m[part])
+		if !ok {
+			sub = make(map[string]interface{})
+		}
+		m[part] = sub
+		m = sub
+	}
+	m[parts[len(parts)-1]] = val
+}
+
+// helper function to flatten settings into key paths
+func flattenSettings(prefix string, settings map[string]interface{}, out map[string]interface{}) {
+	for key, val := range settings {
+		if sub, ok := toMap(val); ok {
+			flattenSettings(joinKey(prefix, key), sub, out)
+			continue
+		}
+		out[joinKey(prefix, key)] = val
+	}
+}
+
+// helper function to delete value of settings by key path
+func deleteKey(settings map[string]interface{}, key string) {
+	parts := strings.Split(strings.ToLower(key), ".")
+	m := settings
+	for _, part := range parts[:len(parts)-1] {
+		sub, ok := m[part].(map[string]interface{})
+		if !ok {
+			return
+		}
+		m = sub
+	}
+	delete(m, parts[len(parts)-1])
+}
@@ -1,22 +1,28 @@
 module github.com/OreCast/common/config
 
-go 1.21.0
+go 1.21.3
 
-require github.com/spf13/viper v1.16.0
+require (
+	github.com/OreCast/common/utils v0.0.0-20231008113920-e5b3f8d8b2d9
+	github.com/fsnotify/fsnotify v1.6.0
+	github.com/mitchellh/mapstructure v1.5.0
+	github.com/spf13/viper v1.16.0
+	github.com/vkuznet/cryptoutils v0.0.2
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
-	github.com/fsnotify/fsnotify v1.6.0 // indirect
 	github.com/hashicorp/hcl v1.0.0 // indirect
 	github.com/magiconair/properties v1.8.7 // indirect
-	github.com/mitchellh/mapstructure v1.5.0 // indirect
 	github.com/pelletier/go-toml/v2 v2.0.8 // indirect
 	github.com/spf13/afero v1.9.5 // indirect
 	github.com/spf13/cast v1.5.1 // indirect
 	github.com/spf13/jwalterweatherman v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/subosito/gotenv v1.4.2 // indirect
+	golang.org/x/crypto v0.9.0 // indirect
+	golang.org/x/exp v0.0.0-20230728194245-b0cb94b80691 // indirect
 	golang.org/x/sys v0.8.0 // indirect
 	golang.org/x/text v0.9.0 // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
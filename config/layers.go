@@ -0,0 +1,207 @@
+package config
+
+// Configuration can be split into several files:
+//   - a file may include other files via include directive, values of
+//     the file override values of included ones, e.g.
+//     include: [common.yaml, secrets.yaml]
+//   - overlay files given by Loader.Overlays are merged on top of
+//     configuration file in given order
+//   - environment overlay selected by Loader.Env or ORECAST_ENV environment
+//     variable, e.g. orecast.yaml with production environment is
+//     overlaid by orecast.production.yaml from the same directory, the
+//     overlay is optional and it is skipped if it does not exist
+//
+// Maps are merged recursively while lists and other values are replaced.
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/OreCast/common/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// IncludeKey defines directive to include other configuration files
+const IncludeKey = "include"
+
+// EnvVar defines environment variable which selects environment overlay
+const EnvVar = "ORECAST_ENV"
+
+// helper function to read configuration file with its includes, names of
+// all read files are appended to read list
+func readLayer(fname string, seen []string, read *[]string) (map[string]interface{}, string, error) {
+	settings, used, err := readFile(fname)
+	if used != "" {
+		*read = append(*read, used)
+	}
+	if err != nil {
+		return nil, used, err
+	}
+	path, _ := filepath.Abs(used)
+	if utils.InList(path, seen) {
+		return nil, used, fmt.Errorf("circular include of %s", used)
+	}
+	seen = append(seen, path)
+	val, ok := settings[IncludeKey]
+	if !ok {
+		return settings, used, nil
+	}
+	delete(settings, IncludeKey)
+	var includes []string
+	switch v := val.(type) {
+	case string:
+		includes = append(includes, v)
+	case []interface{}:
+		for _, item := range v {
+			includes = append(includes, fmt.Sprintf("%v", item))
+		}
+	default:
+		return nil, used, fmt.Errorf("%s: invalid include directive %v", used, val)
+	}
+	out := make(map[string]interface{})
+	for _, inc := range includes {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(used), inc)
+		}
+		sub, _, err := readLayer(inc, seen, read)
+		if err != nil {
+			return nil, used, err
+		}
+		out = mergeMaps(out, sub)
+	}
+	return mergeMaps(out, settings), used, nil
+}
+
+// helper function to return environment overlay file of given file
+func envOverlay(fname, env string) string {
+	ext := filepath.Ext(fname)
+	return fmt.Sprintf("%s.%s%s", strings.TrimSuffix(fname, ext), env, ext)
+}
+
+// helper function to return environment overlay of the loader, it is
+// empty if environment is not set
+func (l *Loader) envFile() string {
+	env := l.Env
+	if env == "" {
+		env = os.Getenv(EnvVar)
+	}
+	if env == "" || l.used == "" {
+		return ""
+	}
+	return envOverlay(l.used, env)
+}
+
+// Files returns list of configuration files which are merged by the loader
+// (without includes), it is available after Parse call
+func (l *Loader) Files() []string {
+	files := []string{l.used}
+	files = append(files, l.Overlays...)
+	if fname := l.envFile(); fname != "" {
+		files = append(files, fname)
+	}
+	return files
+}
+
+// helper function to read and merge all configuration files, it returns
+// settings in namespaced layout and records origins of file values. Every
+// file is normalized before merge, therefore common parameters of overlays
+// override section parameters of previous files.
+func (l *Loader) readLayers() (map[string]interface{}, error) {
+	l.read = nil
+	base, used, err := readLayer(l.File, nil, &l.read)
+	if err != nil {
+		return nil, err
+	}
+	l.used = used
+	layers := []map[string]interface{}{base}
+	files := []string{used}
+	for _, fname := range l.Files()[1:] {
+		if fname == l.envFile() {
+			if _, err := os.Stat(fname); os.IsNotExist(err) {
+				continue
+			}
+		}
+		overlay, _, err := readLayer(fname, nil, &l.read)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, overlay)
+		files = append(files, fname)
+	}
+	// common parameters of every file apply to sections declared by any file
+	l.declared = nil
+	var targets []string
+	for _, section := range Sections {
+		for _, layer := range layers {
+			if _, ok := layer[section]; ok {
+				l.declared = append(l.declared, section)
+				if utils.InList(section, serviceSections) {
+					targets = append(targets, section)
+				}
+				break
+			}
+		}
+	}
+	var settings map[string]interface{}
+	for i, layer := range layers {
+		layer = normalizeLayer(layer, targets)
+		l.recordOrigins(layer, files[i])
+		settings = mergeMaps(settings, layer)
+	}
+	l.merged = copyMap(settings)
+	return settings, nil
+}
+
+// helper function to return all files of the loader including includes,
+// these files are watched for changes
+func (l *Loader) watchedFiles() []string {
+	var out []string
+	for _, fname := range append(l.Files(), l.read...) {
+		if fname == "" {
+			continue
+		}
+		if path, err := filepath.Abs(fname); err == nil {
+			fname = path
+		}
+		if !utils.InList(fname, out) {
+			out = append(out, fname)
+		}
+	}
+	return out
+}
+
+// helper function to record given file as origin of values of its
+// normalized settings
+func (l *Loader) recordOrigins(settings map[string]interface{}, fname string) {
+	flat := make(map[string]interface{})
+	flattenSettings("", settings, flat)
+	for key := range flat {
+		l.origins[key] = fmt.Sprintf("%s:%s", SourceFile, fname)
+	}
+}
+
+// Declared returns sections explicitly declared by configuration files of
+// last Parse call, sections filled only by common parameters, defaults,
+// environment or remote settings are not declared
+func (l *Loader) Declared() []string {
+	return append([]string(nil), l.declared...)
+}
+
+// Merged returns merged settings of all configuration files before
+// applying defaults, environment, flags and decryption
+func (l *Loader) Merged() map[string]interface{} {
+	return copyMap(l.merged)
+}
+
+// Dump writes merged settings of all configuration files in YAML format,
+// it is intended for debugging of layered configuration and secret values
+// are redacted
+func (l *Loader) Dump(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	defer enc.Close()
+	return enc.Encode(Redact("", copyMap(l.merged)))
+}
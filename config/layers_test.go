@@ -0,0 +1,96 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// helper function to write test file
+func writeFile(t *testing.T, fname, data string) {
+	if err := os.WriteFile(fname, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestLayers
+func TestLayers(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "common.yaml"), `
+common:
+  verbose: 1
+  dburi: mongodb://common:8230
+`)
+	cfile := filepath.Join(dir, "orecast.yaml")
+	writeFile(t, cfile, `
+include: common.yaml
+discovery:
+  port: 8320
+  dbname: OreCast
+  dburi: mongodb://base:8230
+metadata:
+  port: 8300
+  dbname: OreCast
+`)
+	overlay := filepath.Join(dir, "local.yaml")
+	writeFile(t, overlay, `
+metadata:
+  port: 8301
+`)
+	writeFile(t, filepath.Join(dir, "orecast.production.yaml"), `
+discovery:
+  dburi: mongodb://production:8230
+authz:
+  client_secret: very-secret
+`)
+	t.Setenv(EnvVar, "production")
+	loader := &Loader{File: cfile, Overlays: []string{overlay}, Sections: []string{DiscoverySection, MetaDataSection}}
+	config, err := loader.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Discovery.DBUri != "mongodb://production:8230" || config.Discovery.Verbose != 1 {
+		t.Errorf("wrong discovery section %+v", config.Discovery)
+	}
+	if config.MetaData.Port != 8301 || config.MetaData.DBUri != "mongodb://common:8230" {
+		t.Errorf("wrong metadata section %+v", config.MetaData)
+	}
+	origins := loader.Origins()
+	if origins["metadata.port"] != SourceFile+":"+overlay {
+		t.Errorf("wrong origin of metadata.port %q", origins["metadata.port"])
+	}
+	var buf bytes.Buffer
+	if err := loader.Dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "mongodb://production:8230") || strings.Contains(buf.String(), IncludeKey) {
+		t.Errorf("wrong dump of merged settings\n%s", buf.String())
+	}
+	if strings.Contains(buf.String(), "very-secret") || loader.Merged()["authz"].(map[string]interface{})["client_secret"] != "very-secret" {
+		t.Errorf("secret values are not redacted in dump\n%s", buf.String())
+	}
+
+	// missing environment overlay is skipped but it is watched
+	loader = &Loader{File: cfile, Env: "staging"}
+	if _, err := loader.Parse(); err != nil {
+		t.Errorf("missing environment overlay is not skipped, error %v", err)
+	}
+	staging, _ := filepath.Abs(filepath.Join(dir, "orecast.staging.yaml"))
+	if files := loader.watchedFiles(); !strings.Contains(strings.Join(files, ","), staging) {
+		t.Errorf("missing environment overlay is not watched %v", files)
+	}
+
+	// missing overlay and circular includes are errors
+	loader = &Loader{File: cfile, Overlays: []string{filepath.Join(dir, "missing.yaml")}}
+	if _, err := loader.Parse(); err == nil {
+		t.Error("missing overlay is accepted")
+	}
+	writeFile(t, filepath.Join(dir, "common.yaml"), "include: orecast.yaml\n")
+	loader = &Loader{File: cfile, Env: ""}
+	t.Setenv(EnvVar, "")
+	if _, err := loader.Parse(); err == nil || !strings.Contains(err.Error(), "circular") {
+		t.Errorf("circular include is not detected, error %v", err)
+	}
+}
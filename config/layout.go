@@ -0,0 +1,184 @@
+package config
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/OreCast/common/utils"
+)
+
+// CommonSection defines name of configuration section with parameters
+// shared by all services
+const CommonSection = "common"
+
+// serviceSections lists sections which inherit parameters from common section
+var serviceSections = []string{
+	FrontendSection,
+	DiscoverySection,
+	MetaDataSection,
+	DataManagementSection,
+	DataBookkeepingSection,
+	AuthzSection,
+}
+
+// legacyBlocks lists sub-sections of legacy layout where embedded structs
+// were stored under their type names, e.g. discovery.webserver.port
+var legacyBlocks = []string{"webserver", "mongodb", "encryption"}
+
+// commonKeys holds lower case names of parameters of service sections,
+// only these names are accepted as common parameters of flat layout
+var commonKeys struct {
+	once sync.Once
+	keys []string
+}
+
+// helper function to check if given top-level key is parameter of service
+// sections or legacy sub-section
+func isCommonKey(key string) bool {
+	commonKeys.once.Do(func() {
+		commonKeys.keys = append(commonKeys.keys, legacyBlocks...)
+		for _, f := range Fields() {
+			parts := strings.SplitN(f.Key, ".", 3)
+			if len(parts) < 2 || !utils.InList(parts[0], serviceSections) {
+				continue
+			}
+			name := strings.ToLower(parts[1])
+			if !utils.InList(name, commonKeys.keys) {
+				commonKeys.keys = append(commonKeys.keys, name)
+			}
+		}
+	})
+	return utils.InList(strings.ToLower(key), commonKeys.keys)
+}
+
+// helper function to convert value to settings map
+func toMap(val interface{}) (map[string]interface{}, bool) {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		return v, true
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if s, ok := key.(string); ok {
+				out[s] = item
+			}
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// helper function to copy settings map
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for key, val := range m {
+		if sub, ok := toMap(val); ok {
+			out[key] = copyMap(sub)
+		} else {
+			out[key] = val
+		}
+	}
+	return out
+}
+
+// mergeMaps deep-merges src settings into dst ones, nested maps are merged
+// while other values (including lists) of src replace values of dst
+func mergeMaps(dst, src map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		dst = make(map[string]interface{})
+	}
+	for key, val := range src {
+		if sub, ok := toMap(val); ok {
+			if dsub, ok := toMap(dst[key]); ok {
+				dst[key] = mergeMaps(dsub, sub)
+				continue
+			}
+			dst[key] = copyMap(sub)
+			continue
+		}
+		dst[key] = val
+	}
+	return dst
+}
+
+// helper function to move parameters of legacy sub-sections into section
+// itself, explicit section parameters take precedence
+func flattenLegacy(section map[string]interface{}) map[string]interface{} {
+	for _, block := range legacyBlocks {
+		sub, ok := toMap(section[block])
+		if !ok {
+			continue
+		}
+		delete(section, block)
+		for key, val := range sub {
+			if _, ok := section[key]; !ok {
+				section[key] = val
+			}
+		}
+	}
+	return section
+}
+
+// normalizeLayout converts settings into namespaced layout where every
+// service section contains its own parameters merged on top of common
+// section. It also reads previous layouts:
+//   - flat layout where parameters of service sections are defined at top
+//     level, such parameters are treated as common ones while other
+//     top-level keys are kept as they are
+//   - legacy layout where service parameters are grouped by embedded
+//     struct names, e.g. discovery.webserver.port
+func normalizeLayout(settings map[string]interface{}) map[string]interface{} {
+	return normalizeLayer(settings, nil)
+}
+
+// helper function to normalize layout of settings, common parameters are
+// applied to given service sections, to declared sections of the settings
+// if none is given, or to all service sections if none is declared
+func normalizeLayer(settings map[string]interface{}, targets []string) map[string]interface{} {
+	out := make(map[string]interface{})
+	common := make(map[string]interface{})
+	var declared []string
+	for key, val := range settings {
+		if key == CommonSection {
+			if sub, ok := toMap(val); ok {
+				common = mergeMaps(common, sub)
+			}
+			continue
+		}
+		if utils.InList(key, Sections) {
+			if sub, ok := toMap(val); ok {
+				out[key] = copyMap(sub)
+				if utils.InList(key, serviceSections) {
+					out[key] = flattenLegacy(copyMap(sub))
+					declared = append(declared, key)
+				}
+			} else {
+				out[key] = val
+			}
+			continue
+		}
+		// flat layout, known parameters are shared by services while
+		// unknown keys (e.g. typos) are not applied to any section
+		if isCommonKey(key) {
+			common[key] = val
+		} else {
+			out[key] = val
+		}
+	}
+	common = flattenLegacy(common)
+	if len(common) == 0 {
+		return out
+	}
+	// in flat layout common parameters apply to all services
+	if len(targets) == 0 {
+		targets = declared
+	}
+	if len(targets) == 0 {
+		targets = serviceSections
+	}
+	for _, section := range targets {
+		sub, _ := toMap(out[section])
+		out[section] = mergeMaps(copyMap(common), sub)
+	}
+	return out
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestLayout checks namespaced, legacy and flat configuration layouts
+func TestLayout(t *testing.T) {
+	layouts := map[string]string{
+		"namespaced": `
+common:
+  dburi: mongodb://localhost:8230
+  dbname: OreCast
+discovery:
+  port: 8320
+metadata:
+  port: 8300
+  dbname: Meta
+`,
+		"legacy": `
+discovery:
+  webserver:
+    port: 8320
+  mongodb:
+    dburi: mongodb://localhost:8230
+    dbname: OreCast
+metadata:
+  webserver:
+    port: 8300
+  mongodb:
+    dburi: mongodb://localhost:8230
+    dbname: Meta
+`,
+	}
+	for name, data := range layouts {
+		cfile := filepath.Join(t.TempDir(), "orecast.yaml")
+		if err := os.WriteFile(cfile, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		config, err := ParseConfig(cfile)
+		if err != nil {
+			t.Fatalf("%s layout: %v", name, err)
+		}
+		if config.Discovery.Port != 8320 || config.MetaData.Port != 8300 {
+			t.Errorf("%s layout: wrong ports %d %d", name, config.Discovery.Port, config.MetaData.Port)
+		}
+		if config.Discovery.DBName != "OreCast" || config.MetaData.DBName != "Meta" {
+			t.Errorf("%s layout: wrong dbnames %s %s", name, config.Discovery.DBName, config.MetaData.DBName)
+		}
+		if config.MetaData.DBUri != "mongodb://localhost:8230" {
+			t.Errorf("%s layout: wrong dburi %s", name, config.MetaData.DBUri)
+		}
+		if config.Authz.Port != 0 {
+			t.Errorf("%s layout: undeclared section inherits common parameters", name)
+		}
+	}
+
+	// flat layout shares all parameters among services
+	settings := normalizeLayout(map[string]interface{}{"port": 8000, "verbose": 1})
+	var config OreCastConfig
+	if err := decodeSettings(settings, &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Frontend.Port != 8000 || config.Authz.Verbose != 1 {
+		t.Errorf("wrong flat layout config %+v", config)
+	}
+
+	// unknown top-level keys are not shared parameters
+	settings = normalizeLayout(map[string]interface{}{"port": 8000, "prot": 9000})
+	discovery, _ := toMap(settings[DiscoverySection])
+	if _, ok := discovery["prot"]; ok || settings["prot"] != 9000 {
+		t.Errorf("unknown key is treated as common parameter %v", settings)
+	}
+}
+
+// TestFlatOverlay checks that common parameters of overlay override
+// section parameters of configuration file
+func TestFlatOverlay(t *testing.T) {
+	dir := t.TempDir()
+	cfile := filepath.Join(dir, "orecast.yaml")
+	if err := os.WriteFile(cfile, []byte("discovery:\n  port: 8320\n  verbose: 1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	overlay := filepath.Join(dir, "local.yaml")
+	if err := os.WriteFile(overlay, []byte("port: 9320\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	loader := &Loader{File: cfile, Overlays: []string{overlay}}
+	config, err := loader.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Discovery.Port != 9320 || config.Discovery.Verbose != 1 {
+		t.Errorf("flat overlay does not override section values %+v", config.Discovery)
+	}
+	if config.Authz.Port != 0 {
+		t.Errorf("flat overlay creates undeclared section %+v", config.Authz)
+	}
+	if origin := loader.Origins()["discovery.port"]; origin != SourceFile+":"+overlay {
+		t.Errorf("wrong origin of discovery.port %q", origin)
+	}
+}
@@ -0,0 +1,246 @@
+package config
+
+// Configuration values are resolved with the following precedence, later
+// sources override earlier ones:
+//
+//  1. defaults, from default struct tags or registered via SetDefault
+//  2. configuration file, keys with _file suffix read value from given
+//     file, e.g. client_secret_file: /run/secrets/client_secret, see
+//     layers.go for includes and overlays
+//  3. remote settings, see Loader.Remote
+//  4. environment variables with ORECAST_ prefix and upper-case key path,
+//     e.g. ORECAST_DISCOVERY_DBURI, variables with _FILE suffix read value
+//     from given file, e.g. ORECAST_AUTHZ_CLIENT_SECRET_FILE
+//  5. command line flags, see Loader.Overrides
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// EnvPrefix defines prefix of environment variables overriding configuration
+const EnvPrefix = "ORECAST_"
+
+// FileSuffix defines suffix of keys and environment variables which point
+// to files holding actual values, e.g. mounted k8s secrets
+const FileSuffix = "_file"
+
+// Source types reported by Loader.Origins
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// defaults holds default values registered via SetDefault
+var defaults = struct {
+	mutex  sync.Mutex
+	values map[string]interface{}
+}{values: make(map[string]interface{})}
+
+// SetDefault registers default value of given key path, e.g. discovery.port
+func SetDefault(key string, val interface{}) {
+	defaults.mutex.Lock()
+	defaults.values[strings.ToLower(key)] = val
+	defaults.mutex.Unlock()
+}
+
+// Loader loads OreCast configuration from all its sources
+type Loader struct {
+	File      string    // configuration file, $HOME/.orecast.yaml by default
+	Sections  []string  // sections to validate, none by default
+	Overrides Overrides // key path values set via command line flags
+	Overlays  []string  // files merged on top of configuration file
+	Env       string    // environment overlay, ORECAST_ENV by default
+	Remote    *Remote   // remote source of settings, see remote.go
+
+	origins  map[string]string      // origins of configuration values
+	used     string                 // configuration file used by last Parse call
+	read     []string               // all files read by last Parse call
+	merged   map[string]interface{} // merged settings of all files
+	declared []string               // sections declared by configuration files
+}
+
+// EnvName returns name of environment variable for given key path
+func EnvName(key string) string {
+	name := strings.NewReplacer(".", "_", "-", "_").Replace(key)
+	return EnvPrefix + strings.ToUpper(name)
+}
+
+// Parse loads, decrypts and validates configuration
+func (l *Loader) Parse() (OreCastConfig, error) {
+	var config OreCastConfig
+	l.origins = make(map[string]string)
+	settings, err := l.readLayers()
+	if err != nil {
+		return config, err
+	}
+	if err := l.readValueFiles(settings); err != nil {
+		return config, err
+	}
+	if l.Remote != nil {
+		l.Remote.apply(settings, l.origins)
+	}
+	l.applyDefaults(settings)
+	if err := l.applyEnv(settings); err != nil {
+		return config, err
+	}
+	l.applyOverrides(settings)
+	if err := decryptSettings(settings); err != nil {
+		return config, err
+	}
+	if err := decodeSettings(settings, &config); err != nil {
+		return config, err
+	}
+	if err := config.Validate(l.Sections...); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// Origins returns origins of configuration values resolved by last Parse
+// call, e.g. "authz.client_secret": "env:ORECAST_AUTHZ_CLIENT_SECRET_FILE"
+func (l *Loader) Origins() map[string]string {
+	out := make(map[string]string, len(l.origins))
+	for key, val := range l.origins {
+		out[key] = val
+	}
+	return out
+}
+
+// helper function to read value from file
+func readValueFile(fname string) (string, error) {
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// helper function to replace keys with _file suffix by content of their files
+func (l *Loader) readValueFiles(settings map[string]interface{}) error {
+	flat := make(map[string]interface{})
+	flattenSettings("", settings, flat)
+	for key, val := range flat {
+		if !strings.HasSuffix(key, FileSuffix) {
+			continue
+		}
+		target := strings.TrimSuffix(key, FileSuffix)
+		if _, ok := lookupField(target); !ok {
+			// key with _file suffix is an actual parameter, e.g. log_file
+			continue
+		}
+		fname := fmt.Sprintf("%v", val)
+		data, err := readValueFile(fname)
+		if err != nil {
+			return fmt.Errorf("%s: unable to read %s, error %v", key, fname, err)
+		}
+		setKey(settings, target, data)
+		l.origins[target] = fmt.Sprintf("%s:%s", SourceFile, fname)
+		delete(l.origins, key)
+		deleteKey(settings, key)
+	}
+	return nil
+}
+
+// helper function to return section of given key path
+func keySection(key string) string {
+	return strings.Split(key, ".")[0]
+}
+
+// helper function to fill missing values of declared sections by defaults
+func (l *Loader) applyDefaults(settings map[string]interface{}) {
+	values := make(map[string]interface{})
+	for _, f := range Fields() {
+		if val, ok := f.Field.Tag.Lookup("default"); ok {
+			values[strings.ToLower(f.Key)] = val
+		}
+	}
+	defaults.mutex.Lock()
+	for key, val := range defaults.values {
+		values[key] = val
+	}
+	defaults.mutex.Unlock()
+	for key, val := range values {
+		// defaults do not declare new sections
+		if _, ok := settings[keySection(key)]; !ok {
+			continue
+		}
+		if _, ok := getKey(settings, key); ok {
+			continue
+		}
+		setKey(settings, key, val)
+		l.origins[key] = SourceDefault
+	}
+}
+
+// helper function to override settings by environment variables
+func (l *Loader) applyEnv(settings map[string]interface{}) error {
+	for _, f := range Fields() {
+		kind := f.Field.Type.Kind()
+		if kind == reflect.Map || (kind == reflect.Slice && f.Field.Type.Elem().Kind() == reflect.Struct) {
+			// lists and maps of records can not be defined via environment
+			continue
+		}
+		key := strings.ToLower(f.Key)
+		name := EnvName(f.Key)
+		if val, ok := os.LookupEnv(name); ok {
+			setKey(settings, key, val)
+			l.origins[key] = fmt.Sprintf("%s:%s", SourceEnv, name)
+		}
+		fname := name + strings.ToUpper(FileSuffix)
+		if path, ok := os.LookupEnv(fname); ok {
+			val, err := readValueFile(path)
+			if err != nil {
+				return fmt.Errorf("%s: unable to read %s, error %v", fname, path, err)
+			}
+			setKey(settings, key, val)
+			l.origins[key] = fmt.Sprintf("%s:%s", SourceEnv, fname)
+		}
+	}
+	return nil
+}
+
+// helper function to override settings by command line flags
+func (l *Loader) applyOverrides(settings map[string]interface{}) {
+	for key, val := range l.Overrides {
+		key = strings.ToLower(key)
+		setKey(settings, key, val)
+		l.origins[key] = SourceFlag
+	}
+}
+
+// Overrides holds configuration values set via command line flags, it
+// implements flag.Value interface, e.g.
+//
+//	overrides := make(config.Overrides)
+//	flag.Var(overrides, "set", "override configuration value, key=value")
+type Overrides map[string]string
+
+// String implements flag.Value interface
+func (o Overrides) String() string {
+	var out []string
+	for key, val := range o {
+		out = append(out, fmt.Sprintf("%s=%s", key, val))
+	}
+	sort.Strings(out)
+	return strings.Join(out, ",")
+}
+
+// Set implements flag.Value interface, it parses key=value pair
+func (o Overrides) Set(arg string) error {
+	parts := strings.SplitN(arg, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("invalid override %q, expect key=value", arg)
+	}
+	if _, ok := lookupField(parts[0]); !ok {
+		return fmt.Errorf("unknown configuration key %q", parts[0])
+	}
+	o[parts[0]] = parts[1]
+	return nil
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestLoaderPrecedence
+func TestLoaderPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "client_secret")
+	if err := os.WriteFile(secret, []byte("from-secret-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfile := filepath.Join(dir, "orecast.yaml")
+	data := `
+authz:
+  port: 8380
+  verbose: 1
+  dburi: mongodb://localhost:8230
+  client_id: file-id
+  client_secret_file: ` + secret + `
+  log_file: /tmp/authz.log
+`
+	if err := os.WriteFile(cfile, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ORECAST_AUTHZ_PORT", "8381")
+	t.Setenv("ORECAST_AUTHZ_CLIENT_ID", "env-id")
+	loader := &Loader{File: cfile, Overrides: Overrides{}}
+	if err := loader.Overrides.Set("authz.client_id=flag-id"); err != nil {
+		t.Fatal(err)
+	}
+	if err := loader.Overrides.Set("authz.unknown=1"); err == nil {
+		t.Error("unknown override key is accepted")
+	}
+	config, err := loader.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Authz.ClientSecret != "from-secret-file" || config.Authz.LogFile != "/tmp/authz.log" {
+		t.Errorf("wrong file based values %q %q", config.Authz.ClientSecret, config.Authz.LogFile)
+	}
+	if config.Authz.Port != 8381 || config.Authz.ClientId != "flag-id" || config.Authz.TokenExpires != 3600 {
+		t.Errorf("wrong precedence of values %+v", config.Authz)
+	}
+	origins := loader.Origins()
+	expect := map[string]string{
+		"authz.verbose":       SourceFile + ":" + cfile,
+		"authz.client_secret": SourceFile + ":" + secret,
+		"authz.port":          SourceEnv + ":ORECAST_AUTHZ_PORT",
+		"authz.client_id":     SourceFlag,
+		"authz.token_expires": SourceDefault,
+	}
+	for key, origin := range expect {
+		if origins[key] != origin {
+			t.Errorf("wrong origin of %s: %q != %q", key, origins[key], origin)
+		}
+	}
+	if _, ok := origins["discovery.cipher"]; ok {
+		t.Error("defaults are applied to undeclared section")
+	}
+}
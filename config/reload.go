@@ -0,0 +1,369 @@
+package config
+
+// hot reload of OreCast configuration
+//
+// Services which need to react on configuration changes subscribe to the
+// sections they use, e.g.
+//
+//	config.Subscribe(config.FrontendSection, func(old, new *config.OreCastConfig) {
+//		auth.UpdateProviders(providerURLs, new.Frontend.Verbose)
+//	})
+//	stopVerbose := config.FollowVerbose(config.FrontendSection)
+//	stop, err := config.Watch()
+//
+// Reloaded configuration is available via Current function, deprecated
+// Config variable holds configuration of Load call.
+
+import (
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/OreCast/common/utils"
+	"github.com/fsnotify/fsnotify"
+)
+
+// current holds currently loaded configuration
+var current atomic.Pointer[OreCastConfig]
+
+// loaded holds loader of current configuration
+var loaded struct {
+	mutex  sync.Mutex
+	loader *Loader
+}
+
+// reloading serializes Reload calls
+var reloading struct {
+	mutex   sync.Mutex
+	running bool // reload is in progress
+	pending bool // reload is requested during running one
+}
+
+// Subscriber defines callback function invoked on configuration change
+type Subscriber func(old, new *OreCastConfig)
+
+// subscription represents registered subscriber of configuration section
+type subscription struct {
+	id      int
+	section string
+	fn      Subscriber
+}
+
+// subscribers holds all registered subscriptions
+var subscribers struct {
+	mutex sync.Mutex
+	last  int
+	list  []subscription
+}
+
+// ReloadDelay defines how long file changes are collected before reload
+var ReloadDelay = 500 * time.Millisecond
+
+// Current returns currently loaded configuration, it is safe to use
+// concurrently with configuration reloads
+func Current() *OreCastConfig {
+	return current.Load()
+}
+
+// helper function to swap current configuration
+func setConfig(c *OreCastConfig) *OreCastConfig {
+	return current.Swap(c)
+}
+
+// Load parses and validates given configuration file and makes it current
+// configuration, the same parameters are used by Reload
+func Load(cfile string, sections ...string) (*OreCastConfig, error) {
+	loader := &Loader{File: cfile, Sections: sections}
+	return loader.Load()
+}
+
+// Load parses configuration and makes it current one, the loader is used
+// by subsequent Reload calls
+func (l *Loader) Load() (*OreCastConfig, error) {
+	config, err := l.Parse()
+	if err != nil {
+		return nil, err
+	}
+	loaded.mutex.Lock()
+	loaded.loader = l
+	loaded.mutex.Unlock()
+	setConfig(&config)
+	// Config is kept for existing services and it is not changed by reloads
+	// since it can not be swapped atomically, new code should use Current()
+	Config = &config
+	return &config, nil
+}
+
+// Origins returns origins of values of current configuration
+func Origins() map[string]string {
+	loaded.mutex.Lock()
+	defer loaded.mutex.Unlock()
+	if loaded.loader == nil {
+		return nil
+	}
+	return loaded.loader.Origins()
+}
+
+// Subscribe registers callback for changes of given configuration section,
+// empty section subscribes to any change. It returns function to cancel
+// the subscription.
+func Subscribe(section string, fn Subscriber) func() {
+	subscribers.mutex.Lock()
+	defer subscribers.mutex.Unlock()
+	subscribers.last += 1
+	id := subscribers.last
+	subscribers.list = append(subscribers.list, subscription{id: id, section: section, fn: fn})
+	return func() {
+		subscribers.mutex.Lock()
+		defer subscribers.mutex.Unlock()
+		var list []subscription
+		for _, s := range subscribers.list {
+			if s.id != id {
+				list = append(list, s)
+			}
+		}
+		subscribers.list = list
+	}
+}
+
+// Changed returns list of sections which differ between two configurations
+func Changed(old, new *OreCastConfig) []string {
+	var out []string
+	for _, section := range Sections {
+		if old == nil || new == nil {
+			out = append(out, section)
+			continue
+		}
+		ov := old.sectionValue(section).Interface()
+		nv := new.sectionValue(section).Interface()
+		if !reflect.DeepEqual(ov, nv) {
+			out = append(out, section)
+		}
+	}
+	return out
+}
+
+// Update makes given configuration current and notifies subscribers of
+// changed sections
+func Update(config *OreCastConfig) {
+	old := setConfig(config)
+	changed := Changed(old, config)
+	if len(changed) == 0 {
+		return
+	}
+	subscribers.mutex.Lock()
+	list := make([]subscription, len(subscribers.list))
+	copy(list, subscribers.list)
+	subscribers.mutex.Unlock()
+	for _, s := range list {
+		if s.section == "" || utils.InList(s.section, changed) {
+			s.fn(old, config)
+		}
+	}
+}
+
+// WebServer returns web server parameters of given service section
+func (c *OreCastConfig) WebServer(section string) (WebServer, bool) {
+	val := c.sectionValue(section)
+	if !val.IsValid() || val.Kind() != reflect.Struct {
+		return WebServer{}, false
+	}
+	ws := val.FieldByName("WebServer")
+	if !ws.IsValid() {
+		return WebServer{}, false
+	}
+	return ws.Interface().(WebServer), true
+}
+
+// MongoDB returns MongoDB parameters of given service section
+func (c *OreCastConfig) MongoDB(section string) (MongoDB, bool) {
+	val := c.sectionValue(section)
+	if !val.IsValid() || val.Kind() != reflect.Struct {
+		return MongoDB{}, false
+	}
+	m := val.FieldByName("MongoDB")
+	if !m.IsValid() {
+		return MongoDB{}, false
+	}
+	return m.Interface().(MongoDB), true
+}
+
+// FollowVerbose sets utils.VERBOSE to verbosity of given service section of
+// current configuration and keeps it up to date on reloads, it returns
+// function to stop following
+func FollowVerbose(section string) func() {
+	if c := Current(); c != nil {
+		if ws, ok := c.WebServer(section); ok {
+			utils.VERBOSE = ws.Verbose
+		}
+	}
+	return Subscribe(section, func(old, new *OreCastConfig) {
+		if ws, ok := new.WebServer(section); ok {
+			utils.VERBOSE = ws.Verbose
+		}
+	})
+}
+
+// Reload reloads configuration with parameters of last Load call, invalid
+// configuration is rejected and current one is kept. Reload requested while
+// another one is running (e.g. by a subscriber) is performed by the running
+// one after it notifies subscribers.
+func Reload() error {
+	reloading.mutex.Lock()
+	if reloading.running {
+		reloading.pending = true
+		reloading.mutex.Unlock()
+		return nil
+	}
+	reloading.running = true
+	reloading.mutex.Unlock()
+	for {
+		err := reload()
+		reloading.mutex.Lock()
+		if err != nil || !reloading.pending {
+			reloading.running = false
+			reloading.pending = false
+			reloading.mutex.Unlock()
+			return err
+		}
+		reloading.pending = false
+		reloading.mutex.Unlock()
+	}
+}
+
+// helper function to parse configuration and notify subscribers, the loader
+// is not locked while subscribers run
+func reload() error {
+	loaded.mutex.Lock()
+	if loaded.loader == nil {
+		loaded.mutex.Unlock()
+		return errors.New("configuration is not loaded")
+	}
+	config, err := loaded.loader.Parse()
+	loaded.mutex.Unlock()
+	if err != nil {
+		log.Println("ERROR: unable to reload configuration, keep current one,", err)
+		return err
+	}
+	Update(&config)
+	return nil
+}
+
+// helper function to return files watched by Watch
+func watchedFiles() []string {
+	loaded.mutex.Lock()
+	defer loaded.mutex.Unlock()
+	if loaded.loader == nil || loaded.loader.used == "" {
+		return nil
+	}
+	return loaded.loader.watchedFiles()
+}
+
+// helper function to check if file event is related to configuration files,
+// k8s ConfigMaps are updated through ..data symlink
+func configEvent(event fsnotify.Event, files []string) bool {
+	name := filepath.Clean(event.Name)
+	if path, err := filepath.Abs(name); err == nil {
+		name = path
+	}
+	if utils.InList(name, files) {
+		return true
+	}
+	return strings.HasPrefix(filepath.Base(event.Name), "..")
+}
+
+// helper function to watch directories of given files, directories are
+// watched to catch editors and k8s ConfigMaps which replace the files
+func watchDirs(watcher *fsnotify.Watcher, files []string, dirs *[]string) error {
+	for _, fname := range files {
+		dir := filepath.Dir(fname)
+		if utils.InList(dir, *dirs) {
+			continue
+		}
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			// directory of missing include can not be watched
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			return err
+		}
+		*dirs = append(*dirs, dir)
+	}
+	return nil
+}
+
+// Watch reloads configuration when any of configuration files (including
+// includes, overlays and environment overlay) changes or process receives
+// SIGHUP signal. It returns function to stop watching.
+func Watch() (func(), error) {
+	files := watchedFiles()
+	if len(files) == 0 {
+		return nil, errors.New("configuration is not loaded from a file")
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	if err := watchDirs(watcher, files, &dirs); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		var timer <-chan time.Time
+		for {
+			select {
+			case <-done:
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !configEvent(event, files) {
+					continue
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+					timer = time.After(ReloadDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Println("ERROR: configuration watcher", err)
+			case <-timer:
+				timer = nil
+				Reload()
+			case <-sigs:
+				log.Println("received SIGHUP, reload configuration")
+				Reload()
+			}
+			// reloaded configuration may include new files
+			if fnames := watchedFiles(); len(fnames) > 0 {
+				files = fnames
+				if err := watchDirs(watcher, files, &dirs); err != nil {
+					log.Println("ERROR: configuration watcher", err)
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			signal.Stop(sigs)
+			close(done)
+			watcher.Close()
+		})
+	}
+	return stop, nil
+}
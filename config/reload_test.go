@@ -0,0 +1,133 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/OreCast/common/utils"
+)
+
+// TestReload
+func TestReload(t *testing.T) {
+	cfile := filepath.Join(t.TempDir(), "orecast.yaml")
+	write := func(port string) {
+		data := []byte("services:\n  authz_url: http://localhost:" + port + "\n")
+		if err := os.WriteFile(cfile, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("8380")
+	if _, err := Load(cfile); err != nil {
+		t.Fatal(err)
+	}
+	changes := make(chan string, 10)
+	cancel := Subscribe(ServicesSection, func(old, new *OreCastConfig) {
+		changes <- new.Services.AuthzURL
+	})
+	defer cancel()
+	Subscribe(FrontendSection, func(old, new *OreCastConfig) {
+		t.Error("frontend subscriber is called without frontend changes")
+	})()
+
+	ReloadDelay = 10 * time.Millisecond
+	stop, err := Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	write("8381")
+	select {
+	case url := <-changes:
+		if url != "http://localhost:8381" {
+			t.Errorf("wrong reloaded value %s", url)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("configuration is not reloaded")
+	}
+	if Current().Services.AuthzURL != "http://localhost:8381" {
+		t.Errorf("current configuration is not updated %+v", Current().Services)
+	}
+
+	// invalid configuration is rejected
+	write("bla\n  dburi")
+	if err := Reload(); err == nil {
+		t.Error("invalid configuration is accepted")
+	}
+	if Current().Services.AuthzURL != "http://localhost:8381" {
+		t.Errorf("invalid configuration replaced current one %+v", Current().Services)
+	}
+}
+
+// TestWatchIncludes
+func TestWatchIncludes(t *testing.T) {
+	dir := t.TempDir()
+	incdir := filepath.Join(dir, "shared")
+	if err := os.Mkdir(incdir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	cfile := filepath.Join(dir, "orecast.yaml")
+	include := filepath.Join(incdir, "services.yaml")
+	write := func(fname, data string) {
+		if err := os.WriteFile(fname, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(include, "services:\n  authz_url: http://localhost:8380\n")
+	write(cfile, "include: shared/services.yaml\nfrontend:\n  port: 8344\n  verbose: 1\n")
+	if _, err := Load(cfile); err != nil {
+		t.Fatal(err)
+	}
+	defer FollowVerbose(FrontendSection)()
+	if utils.VERBOSE != 1 {
+		t.Errorf("wrong verbosity %d", utils.VERBOSE)
+	}
+	// subscribers are called in order of subscription
+	verbose := make(chan int, 10)
+	defer Subscribe(FrontendSection, func(old, new *OreCastConfig) {
+		verbose <- utils.VERBOSE
+	})()
+
+	// subscribers may use Origins and Reload without deadlock
+	changes := make(chan string, 10)
+	cancel := Subscribe(ServicesSection, func(old, new *OreCastConfig) {
+		Origins()
+		Reload()
+		changes <- new.Services.AuthzURL
+	})
+	defer cancel()
+
+	ReloadDelay = 10 * time.Millisecond
+	stop, err := Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	write(include, "services:\n  authz_url: http://localhost:8381\n")
+	select {
+	case url := <-changes:
+		if url != "http://localhost:8381" {
+			t.Errorf("wrong reloaded value %s", url)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("change of included file is not reloaded")
+	}
+	if err := Reload(); err != nil {
+		t.Fatal(err)
+	}
+	write(cfile, "include: shared/services.yaml\nfrontend:\n  port: 8344\n  verbose: 2\n")
+	select {
+	case level := <-verbose:
+		if level != 2 {
+			t.Errorf("wrong reloaded verbosity %d", level)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("verbosity is not reloaded")
+	}
+	if Config.Services.AuthzURL != "http://localhost:8380" {
+		t.Errorf("Config is changed by reload %+v", Config.Services)
+	}
+	// stop can be called several times
+	stop()
+}
@@ -0,0 +1,191 @@
+package config
+
+// remote configuration source
+//
+// Selected keys of configuration can be overlaid by settings stored in
+// MongoDB, e.g. to tune verbosity or rate limits of all hosts at once.
+// Every change is stored as new document with increasing version and the
+// latest version is used, e.g.
+//
+//	{"name": "orecast", "version": 3, "settings": {"discovery": {"verbose": 1}}}
+//
+// Services enable remote source via Loader and poll it for changes,
+// subscribers are notified the same way as on file reload (MongoDB source
+// is provided by mongostore module):
+//
+//	loader := &config.Loader{File: cfile, Remote: &config.Remote{
+//		Source: &mongostore.RemoteSource{DBName: "OreCast", DBColl: "config", Name: "orecast"},
+//		Keys:   []string{"discovery.verbose", "frontend.rate"},
+//	}}
+//	loader.Load()
+//	stop, err := config.WatchRemote()
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SourceRemote defines origin of values provided by remote source
+const SourceRemote = "remote"
+
+// RemoteSettings represents version of remote settings
+type RemoteSettings struct {
+	Version  int64                  // version of settings
+	Settings map[string]interface{} // settings in namespaced layout only
+}
+
+// RemoteSource provides latest version of remote settings
+type RemoteSource interface {
+	Fetch(ctx context.Context) (RemoteSettings, error)
+	String() string
+}
+
+// RemoteTimeout defines how long fetching of remote settings may take, it
+// bounds loading of configuration when remote source is unreachable
+var RemoteTimeout = 5 * time.Second
+
+// Remote overlays configuration with settings of remote source
+type Remote struct {
+	Source   RemoteSource  // remote source of settings
+	Keys     []string      // allowed key paths or sections, all keys if empty
+	Interval time.Duration // polling interval, one minute by default
+	Timeout  time.Duration // timeout of single fetch, RemoteTimeout by default
+
+	mutex  sync.Mutex
+	latest *RemoteSettings // latest fetched settings
+}
+
+// helper function to check if key path can be overlaid
+func (r *Remote) allowed(key string) bool {
+	if len(r.Keys) == 0 {
+		return true
+	}
+	for _, k := range r.Keys {
+		k = strings.ToLower(k)
+		if key == k || strings.HasPrefix(key, k+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Version returns version of latest fetched settings
+func (r *Remote) Version() int64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.latest == nil {
+		return 0
+	}
+	return r.latest.Version
+}
+
+// Poll fetches remote settings and reports if their version is changed
+func (r *Remote) Poll() (bool, error) {
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = RemoteTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	latest, err := r.Source.Fetch(ctx)
+	if err != nil {
+		return false, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.latest != nil && r.latest.Version == latest.Version {
+		return false, nil
+	}
+	r.latest = &latest
+	return true, nil
+}
+
+// helper function to overlay settings by latest remote settings, remote
+// source is fetched if it was not polled yet. Unavailable remote source
+// does not prevent loading of configuration.
+func (r *Remote) apply(settings map[string]interface{}, origins map[string]string) {
+	r.mutex.Lock()
+	polled := r.latest != nil
+	r.mutex.Unlock()
+	if !polled {
+		if _, err := r.Poll(); err != nil {
+			log.Printf("WARNING: unable to fetch remote configuration from %s, error %v", r.Source, err)
+			return
+		}
+	}
+	r.mutex.Lock()
+	latest := r.latest
+	r.mutex.Unlock()
+	// remote settings are not normalized, flat or common parameters would
+	// be spread over all service sections
+	flat := make(map[string]interface{})
+	flattenSettings("", copyMap(latest.Settings), flat)
+	for key, val := range flat {
+		key = strings.ToLower(key)
+		if !r.allowed(key) {
+			log.Printf("WARNING: remote configuration key %s is not allowed", key)
+			continue
+		}
+		if _, ok := lookupField(key); !ok {
+			log.Printf("WARNING: unknown remote configuration key %s", key)
+			continue
+		}
+		// remote settings do not declare new sections
+		if _, ok := settings[keySection(key)]; !ok {
+			continue
+		}
+		setKey(settings, key, val)
+		origins[key] = fmt.Sprintf("%s:%s@%d", SourceRemote, r.Source, latest.Version)
+	}
+}
+
+// WatchRemote polls remote source of current configuration and reloads
+// configuration when new version of remote settings is available. It
+// returns function to stop polling.
+func WatchRemote() (func(), error) {
+	loaded.mutex.Lock()
+	var remote *Remote
+	if loaded.loader != nil {
+		remote = loaded.loader.Remote
+	}
+	loaded.mutex.Unlock()
+	if remote == nil {
+		return nil, errors.New("configuration is not loaded with remote source")
+	}
+	interval := remote.Interval
+	if interval == 0 {
+		interval = time.Minute
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			changed, err := remote.Poll()
+			if err != nil {
+				log.Printf("WARNING: unable to poll remote configuration from %s, error %v", remote.Source, err)
+				continue
+			}
+			if changed {
+				log.Printf("remote configuration version %d is available", remote.Version())
+				Reload()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}, nil
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSource provides remote settings from memory
+type fakeSource struct {
+	mutex  sync.Mutex
+	latest RemoteSettings
+	err    error
+	block  bool // wait until fetch is cancelled
+}
+
+// String implements RemoteSource interface
+func (f *fakeSource) String() string {
+	return "fake"
+}
+
+// Fetch implements RemoteSource interface
+func (f *fakeSource) Fetch(ctx context.Context) (RemoteSettings, error) {
+	if f.block {
+		<-ctx.Done()
+		return RemoteSettings{}, ctx.Err()
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.latest, f.err
+}
+
+// helper function to set remote settings
+func (f *fakeSource) set(version int64, settings map[string]interface{}) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.latest = RemoteSettings{Version: version, Settings: settings}
+}
+
+// TestRemote
+func TestRemote(t *testing.T) {
+	dir := t.TempDir()
+	cfile := filepath.Join(dir, "orecast.yaml")
+	writeFile(t, cfile, `
+discovery:
+  port: 8320
+  verbose: 0
+  dbname: OreCast
+  dburi: mongodb://localhost:8230
+`)
+	source := &fakeSource{}
+	source.set(1, map[string]interface{}{
+		"discovery": map[string]interface{}{"verbose": 1, "port": 9999},
+		"frontend":  map[string]interface{}{"rate": "10-S"},
+	})
+	remote := &Remote{Source: source, Keys: []string{"discovery.verbose", "frontend.rate"}, Interval: 10 * time.Millisecond}
+	loader := &Loader{File: cfile, Remote: remote}
+	config, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sections which are not loaded are not created by remote settings
+	if config.Frontend.LimiterPeriod != "" || loader.Origins()["frontend.rate"] != "" {
+		t.Errorf("remote settings create frontend section %+v", config.Frontend)
+	}
+	if config.Discovery.Verbose != 1 || config.Discovery.Port != 8320 {
+		t.Errorf("wrong remote overlay %+v", config.Discovery)
+	}
+	if origin := loader.Origins()["discovery.verbose"]; origin != "remote:fake@1" {
+		t.Errorf("wrong origin of remote value %q", origin)
+	}
+
+	// new version of remote settings is applied and subscribers are notified
+	notified := make(chan int, 1)
+	cancel := Subscribe(DiscoverySection, func(old, new *OreCastConfig) {
+		notified <- new.Discovery.Verbose
+	})
+	defer cancel()
+	stop, err := WatchRemote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	source.set(2, map[string]interface{}{
+		"discovery": map[string]interface{}{"verbose": 2},
+	})
+	select {
+	case verbose := <-notified:
+		if verbose != 2 {
+			t.Errorf("wrong verbose value %d", verbose)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("remote change is not notified")
+	}
+	if remote.Version() != 2 {
+		t.Errorf("wrong remote version %d", remote.Version())
+	}
+
+	// unreachable remote source does not stall loading
+	loader = &Loader{File: cfile, Remote: &Remote{Source: &fakeSource{block: true}, Timeout: 50 * time.Millisecond}}
+	start := time.Now()
+	if _, err := loader.Parse(); err != nil || time.Since(start) > 5*time.Second {
+		t.Errorf("unreachable remote source is not ignored, error %v", err)
+	}
+
+	// unavailable remote source does not prevent loading
+	loader = &Loader{File: cfile, Remote: &Remote{Source: &fakeSource{err: errors.New("no connection")}}}
+	if config, err := loader.Parse(); err != nil || config.Discovery.Verbose != 0 {
+		t.Errorf("unavailable remote source is not ignored, error %v", err)
+	}
+
+	// flat remote settings are not spread over service sections
+	flat := &fakeSource{}
+	flat.set(1, map[string]interface{}{
+		"verbose": 3,
+		"common":  map[string]interface{}{"verbose": 3},
+	})
+	loader = &Loader{File: cfile, Remote: &Remote{Source: flat}}
+	if config, err := loader.Parse(); err != nil || config.Discovery.Verbose != 0 {
+		t.Errorf("flat remote settings are applied, error %v", err)
+	}
+	for key, origin := range loader.Origins() {
+		if strings.HasPrefix(origin, SourceRemote) {
+			t.Errorf("flat remote settings override %s", key)
+		}
+	}
+}
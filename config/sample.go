@@ -0,0 +1,89 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// sampleHeader describes layout of sample configuration
+const sampleHeader = `# OreCast configuration
+#
+# Every service has its own section, parameters shared by all services can be
+# defined in common section, e.g.
+#
+# common:
+#   dburi: mongodb://localhost:8230
+#
+# Secret values can be encrypted with tools/enc and stored as enc:<hex> values.
+`
+
+// Sample writes sample configuration with all parameters, their descriptions
+// and default values in YAML format
+func Sample(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString(sampleHeader)
+	rtype := reflect.TypeOf(OreCastConfig{})
+	for i := 0; i < rtype.NumField(); i++ {
+		field := rtype.Field(i)
+		name, _ := fieldName(field)
+		fmt.Fprintf(&buf, "\n# %s\n%s:\n", field.Tag.Get("doc"), name)
+		if field.Type.Kind() == reflect.Map {
+			fmt.Fprintf(&buf, "  # name of the entry\n  example:\n")
+			sampleFields(&buf, "    ", field.Type.Elem())
+			continue
+		}
+		sampleFields(&buf, "  ", field.Type)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// helper function to write sample parameters of given struct type
+func sampleFields(buf *bytes.Buffer, indent string, rtype reflect.Type) {
+	for i := 0; i < rtype.NumField(); i++ {
+		field := rtype.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, squash := fieldName(field)
+		if squash {
+			sampleFields(buf, indent, field.Type)
+			continue
+		}
+		comment := field.Tag.Get("doc")
+		def, ok := field.Tag.Lookup("default")
+		if ok {
+			comment = fmt.Sprintf("%s, default %s", comment, def)
+		}
+		fmt.Fprintf(buf, "%s# %s\n", indent, comment)
+		ftype := field.Type
+		if ftype.Kind() == reflect.Slice && ftype.Elem().Kind() == reflect.Struct {
+			fmt.Fprintf(buf, "%s%s:\n%s  -\n", indent, name, indent)
+			sampleFields(buf, indent+"    ", ftype.Elem())
+			continue
+		}
+		fmt.Fprintf(buf, "%s%s: %s\n", indent, name, sampleValue(ftype, def, ok))
+	}
+}
+
+// helper function to return sample value of given type
+func sampleValue(rtype reflect.Type, def string, ok bool) string {
+	switch rtype.Kind() {
+	case reflect.String:
+		return strconv.Quote(def)
+	case reflect.Slice:
+		return "[]"
+	case reflect.Bool:
+		if !ok {
+			return "false"
+		}
+	default:
+		if !ok {
+			return "0"
+		}
+	}
+	return def
+}
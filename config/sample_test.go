@@ -0,0 +1,77 @@
+package config
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// tagPattern defines valid mapstructure names
+var tagPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+// helper function to check tags of all fields of given struct type
+func checkTags(t *testing.T, rtype reflect.Type) {
+	for i := 0; i < rtype.NumField(); i++ {
+		field := rtype.Field(i)
+		tag, ok := field.Tag.Lookup("mapstructure")
+		if !ok {
+			// e.g. unquoted tag value is not recognized
+			t.Errorf("%s.%s has no valid mapstructure tag", rtype.Name(), field.Name)
+			continue
+		}
+		name, squash := fieldName(field)
+		if squash {
+			checkTags(t, field.Type)
+			continue
+		}
+		if tag == "" || !tagPattern.MatchString(name) {
+			t.Errorf("%s.%s has invalid mapstructure tag %q", rtype.Name(), field.Name, tag)
+		}
+		if field.Tag.Get("doc") == "" {
+			t.Errorf("%s.%s has no doc tag", rtype.Name(), field.Name)
+		}
+		switch {
+		case field.Type.Kind() == reflect.Struct:
+			checkTags(t, field.Type)
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct,
+			field.Type.Kind() == reflect.Map && field.Type.Elem().Kind() == reflect.Struct:
+			checkTags(t, field.Type.Elem())
+		}
+	}
+}
+
+// TestFieldTags
+func TestFieldTags(t *testing.T) {
+	checkTags(t, reflect.TypeOf(OreCastConfig{}))
+}
+
+// TestSample
+func TestSample(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Sample(&buf); err != nil {
+		t.Fatal(err)
+	}
+	cfile := filepath.Join(t.TempDir(), "orecast.yaml")
+	writeFile(t, cfile, buf.String())
+	settings, _, err := readFile(cfile)
+	if err != nil {
+		t.Fatalf("sample is not valid YAML, error %v\n%s", err, buf.String())
+	}
+	var config OreCastConfig
+	if err := decodeSettings(normalizeLayout(settings), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Authz.TokenExpires != 3600 || config.Discovery.Cipher != "aes" || config.DataBookkeeping.MaxDBConnections != 100 {
+		t.Errorf("defaults are not set in sample %+v", config)
+	}
+	if len(config.Frontend.OAuth) != 1 {
+		t.Errorf("sample has no oauth record %+v", config.Frontend.OAuth)
+	}
+	for _, f := range Fields() {
+		if _, ok := getKey(settings, f.Key); !ok && f.Field.Type.Kind() != reflect.Slice && f.Field.Type.Kind() != reflect.Map {
+			t.Errorf("sample has no %s parameter", f.Key)
+		}
+	}
+}
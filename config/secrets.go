@@ -0,0 +1,172 @@
+package config
+
+// encrypted values of configuration files
+//
+// Secrets can be stored in configuration as "enc:<hex>" values produced by
+// tools/enc tool, e.g.
+//
+//	enc -action encrypt -cipher aes -secret $MASTER -entry my-client-secret
+//
+// They are decrypted by ParseConfig with master secret provided either via
+// ORECAST_MASTER_SECRET environment variable or via file pointed by
+// ORECAST_MASTER_KEY_FILE environment variable.
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/vkuznet/cryptoutils"
+)
+
+// EncPrefix defines prefix of encrypted configuration values
+const EncPrefix = "enc:"
+
+// Redacted defines replacement of secret values, see Redact
+const Redacted = "xxxxxx"
+
+// secretKeys lists parts of key names which hold secret values
+var secretKeys = []string{"secret", "password", "token"}
+
+// environment variables used to obtain master secret and its cipher
+const (
+	MasterSecretEnv  = "ORECAST_MASTER_SECRET"
+	MasterKeyFileEnv = "ORECAST_MASTER_KEY_FILE"
+	MasterCipherEnv  = "ORECAST_MASTER_CIPHER"
+)
+
+// ErrNoMasterSecret is returned when configuration contains encrypted
+// values but master secret is not provided
+var ErrNoMasterSecret = errors.New("master secret is not provided, please set " +
+	MasterSecretEnv + " or " + MasterKeyFileEnv + " environment variable")
+
+// helper function to obtain master secret
+func masterSecret() (string, error) {
+	if secret := os.Getenv(MasterSecretEnv); secret != "" {
+		return secret, nil
+	}
+	if fname := os.Getenv(MasterKeyFileEnv); fname != "" {
+		data, err := os.ReadFile(fname)
+		if err != nil {
+			return "", fmt.Errorf("unable to read master key file %s, error %v", fname, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return "", ErrNoMasterSecret
+}
+
+// helper function to obtain cipher of master secret, by default it is
+// cipher of encryption section or aes
+func masterCipher(settings map[string]interface{}) string {
+	if cipher := os.Getenv(MasterCipherEnv); cipher != "" {
+		return cipher
+	}
+	if enc, ok := toMap(settings[EncryptionSection]); ok {
+		if cipher, ok := enc["cipher"].(string); ok && cipher != "" {
+			return cipher
+		}
+	}
+	return "aes"
+}
+
+// decryptSettings replaces all encrypted values of settings with their
+// decrypted counterparts, errors only refer to keys of encrypted values
+// and never contain the values themselves
+func decryptSettings(settings map[string]interface{}) error {
+	var secret, cipher string
+	var walk func(path string, val interface{}) (interface{}, error)
+	walk = func(path string, val interface{}) (interface{}, error) {
+		switch v := val.(type) {
+		case string:
+			if !strings.HasPrefix(v, EncPrefix) {
+				return v, nil
+			}
+			if secret == "" {
+				var err error
+				if secret, err = masterSecret(); err != nil {
+					return nil, fmt.Errorf("%s: %w", path, err)
+				}
+				cipher = masterCipher(settings)
+			}
+			data, err := cryptoutils.HexDecrypt(strings.TrimPrefix(v, EncPrefix), secret, cipher)
+			if err != nil {
+				return nil, fmt.Errorf("%s: unable to decrypt value with %s cipher", path, cipher)
+			}
+			return data, nil
+		case []interface{}:
+			for i, item := range v {
+				out, err := walk(fmt.Sprintf("%s[%d]", path, i), item)
+				if err != nil {
+					return nil, err
+				}
+				v[i] = out
+			}
+			return v, nil
+		}
+		if m, ok := toMap(val); ok {
+			for key, item := range m {
+				out, err := walk(joinKey(path, key), item)
+				if err != nil {
+					return nil, err
+				}
+				m[key] = out
+			}
+			return m, nil
+		}
+		return val, nil
+	}
+	_, err := walk("", settings)
+	return err
+}
+
+// helper function to join configuration key path
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// helper function to check if key holds secret value
+func isSecret(key string) bool {
+	key = strings.ToLower(key)
+	for _, part := range secretKeys {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact replaces secret values of given settings value stored under given
+// key, it also hides passwords of URIs. Maps and lists are redacted in place.
+func Redact(key string, val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = Redact(k, item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = Redact(key, item)
+		}
+		return v
+	case string:
+		if v == "" {
+			return v
+		}
+		if isSecret(key) {
+			return Redacted
+		}
+		if u, err := url.Parse(v); err == nil && u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				u.User = url.UserPassword(u.User.Username(), Redacted)
+				return u.String()
+			}
+		}
+	}
+	return val
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vkuznet/cryptoutils"
+)
+
+// TestEncryptedValues
+func TestEncryptedValues(t *testing.T) {
+	entry, err := cryptoutils.HexEncrypt("top-secret", "master", "aes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfile := filepath.Join(t.TempDir(), "orecast.yaml")
+	data := "authz:\n  port: 8380\n  dburi: mongodb://localhost:8230\n  client_id: id\n  client_secret: " + EncPrefix + entry + "\n"
+	if err := os.WriteFile(cfile, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(MasterSecretEnv, "")
+	if _, err := ParseConfig(cfile); !errors.Is(err, ErrNoMasterSecret) {
+		t.Errorf("missing master secret returns %v", err)
+	}
+	t.Setenv(MasterSecretEnv, "wrong")
+	_, err = ParseConfig(cfile)
+	if err == nil || strings.Contains(err.Error(), "top-secret") || !strings.Contains(err.Error(), "authz.client_secret") {
+		t.Errorf("wrong decryption error %v", err)
+	}
+	t.Setenv(MasterSecretEnv, "master")
+	config, err := ParseConfig(cfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Authz.ClientSecret != "top-secret" {
+		t.Errorf("value is not decrypted")
+	}
+}
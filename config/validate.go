@@ -0,0 +1,256 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/OreCast/common/utils"
+)
+
+// Section names of OreCastConfig used to scope validation
+const (
+	FrontendSection        = "frontend"
+	DiscoverySection       = "discovery"
+	MetaDataSection        = "metadata"
+	DataManagementSection  = "datamanagement"
+	DataBookkeepingSection = "databookkeeping"
+	AuthzSection           = "authz"
+	ServicesSection        = "services"
+	EncryptionSection      = "encryption"
+	FeaturesSection        = "features"
+)
+
+// Sections lists all sections of OreCastConfig
+var Sections = []string{
+	FrontendSection,
+	DiscoverySection,
+	MetaDataSection,
+	DataManagementSection,
+	DataBookkeepingSection,
+	AuthzSection,
+	ServicesSection,
+	EncryptionSection,
+	FeaturesSection,
+}
+
+// FieldError represents validation error of single configuration field
+type FieldError struct {
+	Field   string // configuration key path, e.g. frontend.port
+	Message string // description of the problem
+}
+
+// Error implements error interface
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationError aggregates all problems found in configuration
+type ValidationError []FieldError
+
+// Error implements error interface
+func (v ValidationError) Error() string {
+	var out []string
+	for _, e := range v {
+		out = append(out, e.Error())
+	}
+	return fmt.Sprintf("invalid configuration:\n%s", strings.Join(out, "\n"))
+}
+
+// Unwrap returns individual field errors
+func (v ValidationError) Unwrap() []error {
+	var out []error
+	for _, e := range v {
+		out = append(out, e)
+	}
+	return out
+}
+
+// validator collects field errors
+type validator struct {
+	errors ValidationError
+}
+
+// helper function to record field error
+func (v *validator) fail(field, format string, args ...interface{}) {
+	v.errors = append(v.errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// helper function to check that string value is provided
+func (v *validator) required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.fail(field, "value is required")
+	}
+}
+
+// helper function to check that file exists
+func (v *validator) file(field, fname string) {
+	if fname == "" {
+		return
+	}
+	if _, err := os.Stat(fname); err != nil {
+		v.fail(field, "file %s is not accessible: %v", fname, err)
+	}
+}
+
+// helper function to check URL value, it may contain comma separated list
+// of URLs of service replicas
+func (v *validator) url(field, value string) {
+	if value == "" {
+		return
+	}
+	for _, rurl := range strings.Split(value, ",") {
+		rurl = strings.TrimSpace(rurl)
+		u, err := url.Parse(rurl)
+		if err != nil {
+			v.fail(field, "invalid URL %q: %v", rurl, err)
+			continue
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.fail(field, "invalid URL %q, expect http(s)://host[:port]", rurl)
+		}
+	}
+}
+
+// helper function to validate web server parameters
+func (v *validator) webServer(prefix string, w WebServer) {
+	if w.Port <= 0 || w.Port > 65535 {
+		v.fail(prefix+".port", "invalid port number %d", w.Port)
+	}
+	if (w.ServerCrt == "") != (w.ServerKey == "") {
+		v.fail(prefix+".server_cert", "server_cert and server_key should be provided together")
+	}
+	v.file(prefix+".server_cert", w.ServerCrt)
+	v.file(prefix+".server_key", w.ServerKey)
+	v.file(prefix+".rootCAs", w.RootCAs)
+}
+
+// helper function to validate MongoDB parameters
+func (v *validator) mongoDB(prefix string, m MongoDB) {
+	v.required(prefix+".dbname", m.DBName)
+	v.dbUri(prefix+".dburi", m.DBUri)
+	if m.PoolSize < 0 || m.ConnectTimeout < 0 || m.Timeout < 0 {
+		v.fail(prefix, "MongoDB pool size and timeouts must not be negative")
+	}
+	if m.ReadPreference != "" && !utils.InList(strings.ToLower(m.ReadPreference), readPreferences) {
+		v.fail(prefix+".read_preference", "invalid read preference %s", m.ReadPreference)
+	}
+	if m.WriteConcern != "" && m.WriteConcern != "majority" {
+		if n, err := strconv.Atoi(m.WriteConcern); err != nil || n < 0 {
+			v.fail(prefix+".write_concern", "invalid write concern %s, expect majority or number of nodes", m.WriteConcern)
+		}
+	}
+}
+
+// readPreferences defines allowed MongoDB read preferences
+var readPreferences = []string{"primary", "primarypreferred", "secondary", "secondarypreferred", "nearest"}
+
+// helper function to validate MongoDB URI
+func (v *validator) dbUri(field, uri string) {
+	v.required(field, uri)
+	if uri != "" && !strings.HasPrefix(uri, "mongodb://") && !strings.HasPrefix(uri, "mongodb+srv://") {
+		v.fail(field, "invalid MongoDB URI, expect mongodb:// or mongodb+srv:// scheme")
+	}
+}
+
+// helper function to validate encryption parameters, secret is checked
+// when encryption is in use, i.e. required by the section
+func (v *validator) encryption(prefix string, e Encryption, inUse bool) {
+	if e.Cipher != "" && e.Cipher != "aes" && e.Cipher != "nacl" {
+		v.fail(prefix+".cipher", "unsupported cipher %q, expect aes or nacl", e.Cipher)
+	}
+	if inUse {
+		v.required(prefix+".secret", e.Secret)
+	}
+}
+
+// helper function to validate given section
+func (v *validator) section(c *OreCastConfig, section string) {
+	switch section {
+	case FrontendSection:
+		v.webServer("frontend", c.Frontend.WebServer)
+		for i, rec := range c.Frontend.OAuth {
+			prefix := fmt.Sprintf("frontend.oauth[%d]", i)
+			v.required(prefix+".provider", rec.Provider)
+			v.required(prefix+".client_id", rec.ClientID)
+			v.required(prefix+".client_secret", rec.ClientSecret)
+		}
+	case DiscoverySection:
+		v.webServer("discovery", c.Discovery.WebServer)
+		v.mongoDB("discovery", c.Discovery.MongoDB)
+		v.encryption("discovery", c.Discovery.Encryption, false)
+	case MetaDataSection:
+		v.webServer("metadata", c.MetaData.WebServer)
+		v.mongoDB("metadata", c.MetaData.MongoDB)
+	case DataManagementSection:
+		v.webServer("datamanagement", c.DataManagement.WebServer)
+	case DataBookkeepingSection:
+		v.webServer("databookkeeping", c.DataBookkeeping.WebServer)
+		v.required("databookkeeping.dbfile", c.DataBookkeeping.DBFile)
+		if c.DataBookkeeping.MaxDBConnections < 0 {
+			v.fail("databookkeeping.max_db_connections", "negative value %d", c.DataBookkeeping.MaxDBConnections)
+		}
+		if c.DataBookkeeping.MaxIdleConnections < 0 {
+			v.fail("databookkeeping.max_idle_connections", "negative value %d", c.DataBookkeeping.MaxIdleConnections)
+		}
+	case AuthzSection:
+		v.webServer("authz", c.Authz.WebServer)
+		v.encryption("authz", c.Authz.Encryption, false)
+		v.dbUri("authz.dburi", c.Authz.DBUri)
+		v.required("authz.client_id", c.Authz.ClientId)
+		v.required("authz.client_secret", c.Authz.ClientSecret)
+		if c.Authz.TokenExpires < 0 {
+			v.fail("authz.token_expires", "negative value %d", c.Authz.TokenExpires)
+		}
+	case ServicesSection:
+		v.url("services.frontend_url", c.Services.FrontendURL)
+		v.url("services.discovery_url", c.Services.DiscoveryURL)
+		v.url("services.metadata_url", c.Services.MetaDataURL)
+		v.url("services.datamanagement_url", c.Services.DataManagementURL)
+		v.url("services.databookkeeping_url", c.Services.DataBookkeepingURL)
+		v.url("services.authz_url", c.Services.AuthzURL)
+	case EncryptionSection:
+		// encryption section is only used by services which encrypt data
+		v.encryption("encryption", c.Encryption, true)
+	case FeaturesSection:
+		for name, f := range c.Features {
+			prefix := "features." + name
+			if f.Percentage < 0 || f.Percentage > 100 {
+				v.fail(prefix+".percentage", "invalid percentage %d, expect 0-100", f.Percentage)
+			}
+			if f.Key != "" && f.Key != "user" && f.Key != "site" {
+				v.fail(prefix+".key", "invalid key %q, expect user or site", f.Key)
+			}
+		}
+	default:
+		v.fail(section, "unknown configuration section")
+	}
+}
+
+// sectionValue returns value of given configuration section
+func (c *OreCastConfig) sectionValue(section string) reflect.Value {
+	val := reflect.ValueOf(c).Elem()
+	for i := 0; i < val.NumField(); i++ {
+		if strings.ToLower(val.Type().Field(i).Name) == section {
+			return val.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+// Validate validates given sections of configuration, nothing is validated
+// if no sections are provided. It returns ValidationError with all found
+// problems.
+func (c *OreCastConfig) Validate(sections ...string) error {
+	v := &validator{}
+	for _, section := range sections {
+		v.section(c, strings.ToLower(section))
+	}
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestValidate
+func TestValidate(t *testing.T) {
+	var config OreCastConfig
+	if err := config.Validate(Sections...); err == nil {
+		t.Error("empty config is valid")
+	}
+	config.Discovery.Port = 8320
+	config.Discovery.DBUri = "localhost:27017"
+	config.Discovery.WriteConcern = "all"
+	config.Services.AuthzURL = "authz"
+	config.Features = map[string]Feature{"search": {Percentage: 120, Key: "group"}}
+	// nothing is validated if no sections are given
+	if err := config.Validate(); err != nil {
+		t.Errorf("sections are validated by default, error %v", err)
+	}
+	err := config.Validate(DiscoverySection, ServicesSection, FeaturesSection)
+	var verr ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("wrong validation error %v", err)
+	}
+	fields := make(map[string]bool)
+	for _, e := range verr {
+		fields[e.Field] = true
+	}
+	for _, f := range []string{"discovery.write_concern", "discovery.dbname", "discovery.dburi", "services.authz_url",
+		"features.search.percentage", "features.search.key"} {
+		if !fields[f] {
+			t.Errorf("missing validation error for %s in %v", f, err)
+		}
+	}
+	// scoped validation only checks requested sections
+	if err := config.Validate(ServicesSection); err == nil || len(err.(ValidationError)) != 1 {
+		t.Errorf("wrong scoped validation error %v", err)
+	}
+}
+
+// TestParseConfigValidation
+func TestParseConfigValidation(t *testing.T) {
+	cfile := filepath.Join(t.TempDir(), "orecast.yaml")
+	data := []byte("authz:\n  webserver:\n    port: 8380\n  client_id: id\n")
+	if err := os.WriteFile(cfile, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseConfig(cfile); err != nil {
+		t.Errorf("sections are validated by default, error %v", err)
+	}
+	_, err := ParseConfig(cfile, AuthzSection)
+	var verr ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("wrong validation error %v", err)
+	}
+	if _, err := ParseConfig(cfile, FrontendSection); err == nil {
+		t.Error("missing frontend section is not reported")
+	}
+}
+
+// TestDeclared
+func TestDeclared(t *testing.T) {
+	dir := t.TempDir()
+	cfile := filepath.Join(dir, "orecast.yaml")
+	// flat layout, common section and environment do not declare sections
+	data := []byte("port: 8320\ndburi: mongodb://localhost:8230\ndbname: OreCast\ncommon:\n  verbose: 1\ndiscovery:\n  port: 8321\n")
+	if err := os.WriteFile(cfile, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ORECAST_FRONTEND_RATE", "10")
+	loader := &Loader{File: cfile}
+	if _, err := loader.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if declared := loader.Declared(); len(declared) != 1 || declared[0] != DiscoverySection {
+		t.Errorf("wrong declared sections %v", declared)
+	}
+}
+
+// TestValidateEncryption
+func TestValidateEncryption(t *testing.T) {
+	var config OreCastConfig
+	config.Discovery.Encryption = Encryption{Cipher: "des"}
+	config.Encryption = Encryption{Cipher: "des"}
+	err := config.Validate(DiscoverySection, EncryptionSection)
+	fields := make(map[string]bool)
+	if verr, ok := err.(ValidationError); ok {
+		for _, e := range verr {
+			fields[e.Field] = true
+		}
+	}
+	for _, f := range []string{"discovery.cipher", "encryption.cipher", "encryption.secret"} {
+		if !fields[f] {
+			t.Errorf("missing validation error for %s in %v", f, err)
+		}
+	}
+	if fields["discovery.secret"] {
+		t.Errorf("secret of unused encryption is required, error %v", err)
+	}
+}
+
+// TestParseConfigReplicas
+func TestParseConfigReplicas(t *testing.T) {
+	cfile := filepath.Join(t.TempDir(), "orecast.yaml")
+	write := func(urls string) {
+		data := []byte("services:\n  discovery_url: " + urls + "\n")
+		if err := os.WriteFile(cfile, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("http://host1:8320, https://host2:8320")
+	config, err := ParseConfig(cfile, ServicesSection)
+	if err != nil {
+		t.Fatalf("replicas are rejected, error %v", err)
+	}
+	if config.Services.DiscoveryURL != "http://host1:8320, https://host2:8320" {
+		t.Errorf("wrong replicas %q", config.Services.DiscoveryURL)
+	}
+	write("http://host1:8320,host2:8320")
+	_, err = ParseConfig(cfile, ServicesSection)
+	var verr ValidationError
+	if !errors.As(err, &verr) || !strings.Contains(err.Error(), "services.discovery_url") {
+		t.Errorf("invalid replica is not reported, error %v", err)
+	}
+}
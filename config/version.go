@@ -0,0 +1,108 @@
+package config
+
+// version information of OreCast services
+//
+// It is obtained from build information embedded by Go toolchain, i.e. module
+// version and VCS revision, modification flag and commit time. Build time is
+// not recorded by Go toolchain, it and other values are set at build time, e.g.
+//
+//	go build -ldflags "-X github.com/OreCast/common/config.Version=v1.2.3 \
+//		-X github.com/OreCast/common/config.BuildTime=`date -u +%Y-%m-%dT%H:%M:%SZ`"
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+)
+
+// build parameters set via -ldflags -X option, they take precedence over
+// build information of Go toolchain
+var (
+	Version   string // version of the service
+	Revision  string // VCS revision of the service
+	BuildTime string // build time in RFC3339 format, not set by default
+)
+
+// readBuildInfo obtains build information of running binary
+var readBuildInfo = debug.ReadBuildInfo
+
+// BuildInfo represents version information of the service
+type BuildInfo struct {
+	Version    string `json:"version"`               // version of the service
+	Revision   string `json:"revision,omitempty"`    // VCS revision
+	Dirty      bool   `json:"dirty,omitempty"`       // binary is built from modified sources
+	CommitTime string `json:"commit_time,omitempty"` // VCS commit time in RFC3339 format
+	BuildTime  string `json:"build_time,omitempty"`  // build time in RFC3339 format, set via ldflags
+	GoVersion  string `json:"go_version"`            // version of Go compiler
+	Module     string `json:"module,omitempty"`      // main module path
+}
+
+// Build returns version information of the service
+func Build() BuildInfo {
+	info := BuildInfo{GoVersion: runtime.Version()}
+	if bi, ok := readBuildInfo(); ok {
+		info.Module = bi.Main.Path
+		if bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		if bi.GoVersion != "" {
+			info.GoVersion = bi.GoVersion
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				info.Revision = s.Value
+			case "vcs.modified":
+				info.Dirty, _ = strconv.ParseBool(s.Value)
+			case "vcs.time":
+				info.CommitTime = s.Value
+			}
+		}
+	}
+	if Version != "" {
+		info.Version = Version
+	}
+	if Revision != "" {
+		info.Revision = Revision
+	}
+	if BuildTime != "" {
+		info.BuildTime = BuildTime
+	}
+	if info.Version == "" {
+		info.Version = "devel"
+	}
+	return info
+}
+
+// String returns version information in key=value form
+func (b BuildInfo) String() string {
+	rev := b.Revision
+	if rev == "" {
+		rev = "unknown"
+	}
+	if b.Dirty {
+		rev += "-dirty"
+	}
+	out := fmt.Sprintf("version=%s git=%s go=%s commit=%s", b.Version, rev, b.GoVersion, b.CommitTime)
+	if b.BuildTime != "" {
+		out += " date=" + b.BuildTime
+	}
+	return out
+}
+
+// JSON returns version information in JSON format
+func (b BuildInfo) JSON() []byte {
+	data, _ := json.Marshal(b)
+	return data
+}
+
+// VersionHandler provides version information of the service in JSON
+// format, e.g. for /version end-point
+func VersionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(Build().JSON())
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+// TestBuildInfo
+func TestBuildInfo(t *testing.T) {
+	defer func(f func() (*debug.BuildInfo, bool)) { readBuildInfo = f }(readBuildInfo)
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{
+			GoVersion: "go1.21.3",
+			Main:      debug.Module{Path: "github.com/OreCast/Discovery", Version: "v0.1.0"},
+			Settings: []debug.BuildSetting{
+				{Key: "vcs.revision", Value: "e5b3f8d8b2d9"},
+				{Key: "vcs.modified", Value: "true"},
+				{Key: "vcs.time", Value: "2023-10-08T11:39:20Z"},
+			},
+		}, true
+	}
+	info := Build()
+	if info.Version != "v0.1.0" || info.Revision != "e5b3f8d8b2d9" || !info.Dirty || info.CommitTime != "2023-10-08T11:39:20Z" {
+		t.Errorf("wrong build info %+v", info)
+	}
+	// commit time is not reported as build time
+	if info.BuildTime != "" {
+		t.Errorf("build time is set without ldflags %+v", info)
+	}
+	if !strings.Contains(info.String(), "git=e5b3f8d8b2d9-dirty") || strings.Contains(info.String(), "date=") {
+		t.Errorf("wrong build info string %s", info)
+	}
+
+	// ldflags values take precedence
+	defer func() { Version, BuildTime = "", "" }()
+	Version, BuildTime = "v1.2.3", "2024-01-01T00:00:00Z"
+	if info = Build(); info.Version != "v1.2.3" || info.BuildTime != "2024-01-01T00:00:00Z" || info.CommitTime == "" {
+		t.Errorf("ldflags values are not used %+v", info)
+	}
+
+	rec := httptest.NewRecorder()
+	VersionHandler(rec, httptest.NewRequest("GET", "/version", nil))
+	var out BuildInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || out != info {
+		t.Errorf("wrong version response %d %+v", rec.Code, out)
+	}
+}
@@ -2,9 +2,9 @@ package data
 
 // MetaData represents meta-data object
 type MetaData struct {
-	ID          string   `json:"id"`
-	Site        string   `json:"site"`
-	Description string   `json:"description"`
-	Tags        []string `json:"tags"`
-	Bucket      string   `json:"bucket"`
+	ID          string   `json:"id" bson:"id"`
+	Site        string   `json:"site" bson:"site"`
+	Description string   `json:"description" bson:"description"`
+	Tags        []string `json:"tags" bson:"tags"`
+	Bucket      string   `json:"bucket" bson:"bucket"`
 }
@@ -2,11 +2,11 @@ package data
 
 // Site represents Site object returned from discovery service
 type Site struct {
-	Name         string `json:"name" binding:"required"`
-	URL          string `json:"url" binding:"required"`
-	Endpoint     string `json:"endpoint" binding:"required"`
-	AccessKey    string `json:"access_key" binding:"required"`
-	AccessSecret string `json:"access_secret" binding:"required"`
-	UseSSL       bool   `json:"use_ssl"`
-	Description  string `json:"description"`
+	Name         string `json:"name" bson:"name" binding:"required"`
+	URL          string `json:"url" bson:"url" binding:"required"`
+	Endpoint     string `json:"endpoint" bson:"endpoint" binding:"required"`
+	AccessKey    string `json:"access_key" bson:"access_key" binding:"required"`
+	AccessSecret string `json:"access_secret" bson:"access_secret" binding:"required"`
+	UseSSL       bool   `json:"use_ssl" bson:"use_ssl"`
+	Description  string `json:"description" bson:"description"`
 }
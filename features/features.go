@@ -0,0 +1,177 @@
+package features
+
+// feature flags of OreCast services
+//
+// Flags are defined in features section of configuration and optionally in
+// MongoDB collection, e.g.
+//
+//	features:
+//	  new_search:
+//	    percentage: 20
+//	    users: [alice]
+//	  site_quota:
+//	    key: site
+//	    sites: [T1_US_FNAL]
+//
+// and evaluated for a user or a site:
+//
+//	flags, stop := features.FromConfig()
+//	defer stop()
+//	if flags.Enabled("new_search", features.Subject{User: login}) {
+//		...
+//	}
+
+import (
+	"hash/fnv"
+	"strings"
+	"sync"
+
+	"github.com/OreCast/common/config"
+	"github.com/OreCast/common/utils"
+)
+
+// Subject represents user and site for which features are evaluated
+type Subject struct {
+	User string // user name
+	Site string // site name
+}
+
+// Store provides feature flags from external storage
+type Store interface {
+	Features() (map[string]config.Feature, error)
+}
+
+// Flags holds feature flags
+type Flags struct {
+	Store   Store // optional storage of flags, its flags override configuration
+	Verbose int   // verbosity level
+
+	mutex  sync.RWMutex
+	base   map[string]config.Feature // flags of configuration
+	stored map[string]config.Feature // flags of the store
+	flags  map[string]config.Feature // effective flags
+}
+
+// New creates feature flags from given definitions
+func New(features map[string]config.Feature) *Flags {
+	f := &Flags{}
+	f.Set(features)
+	return f
+}
+
+// FromConfig creates feature flags from current configuration and keeps
+// them up to date on configuration reloads until returned stop function
+// is called
+func FromConfig() (*Flags, func()) {
+	f := &Flags{}
+	if c := config.Current(); c != nil {
+		f.Set(c.Features)
+	}
+	stop := config.Subscribe(config.FeaturesSection, func(old, new *config.OreCastConfig) {
+		f.Set(new.Features)
+	})
+	return f, stop
+}
+
+// helper function to normalize feature names
+func normalize(features map[string]config.Feature) map[string]config.Feature {
+	out := make(map[string]config.Feature, len(features))
+	for name, feature := range features {
+		out[strings.ToLower(name)] = feature
+	}
+	return out
+}
+
+// Set replaces flags of configuration
+func (f *Flags) Set(features map[string]config.Feature) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.base = normalize(features)
+	f.merge()
+}
+
+// Refresh reloads flags of the store, they override flags of configuration,
+// previous flags are kept if the store fails
+func (f *Flags) Refresh() error {
+	if f.Store == nil {
+		return nil
+	}
+	stored, err := f.Store.Features()
+	if err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.stored = normalize(stored)
+	f.merge()
+	return nil
+}
+
+// helper function to merge flags of configuration and store
+func (f *Flags) merge() {
+	flags := make(map[string]config.Feature, len(f.base)+len(f.stored))
+	for name, feature := range f.base {
+		flags[name] = feature
+	}
+	for name, feature := range f.stored {
+		flags[name] = feature
+	}
+	f.flags = flags
+}
+
+// Names returns names of all defined features
+func (f *Flags) Names() []string {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	var out []string
+	for name := range f.flags {
+		out = append(out, name)
+	}
+	return out
+}
+
+// Enabled reports if given feature is enabled for given subject, unknown
+// features are disabled
+func (f *Flags) Enabled(name string, subject Subject) bool {
+	name = strings.ToLower(name)
+	f.mutex.RLock()
+	feature, ok := f.flags[name]
+	f.mutex.RUnlock()
+	if !ok {
+		return false
+	}
+	return Evaluate(name, feature, subject)
+}
+
+// Evaluate reports if feature is enabled for given subject: it is enabled
+// for everyone, for listed users and sites, or for stable percentage of
+// users (or sites if feature key is site)
+func Evaluate(name string, feature config.Feature, subject Subject) bool {
+	if feature.Enabled {
+		return true
+	}
+	if subject.User != "" && utils.InList(subject.User, feature.Users) {
+		return true
+	}
+	if subject.Site != "" && utils.InList(subject.Site, feature.Sites) {
+		return true
+	}
+	if feature.Percentage <= 0 {
+		return false
+	}
+	key := subject.User
+	if feature.Key == "site" {
+		key = subject.Site
+	}
+	if key == "" {
+		return false
+	}
+	return bucket(name, key) < feature.Percentage
+}
+
+// helper function to assign key to one of 100 buckets of the feature
+func bucket(name, key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name + ":" + key))
+	return int(h.Sum32() % 100)
+}
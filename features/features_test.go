@@ -0,0 +1,123 @@
+package features
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/OreCast/common/config"
+	"github.com/gin-gonic/gin"
+)
+
+// fakeStore provides feature flags from memory
+type fakeStore map[string]config.Feature
+
+// Features implements Store interface
+func (f fakeStore) Features() (map[string]config.Feature, error) {
+	return f, nil
+}
+
+// failingStore fails to provide feature flags
+type failingStore struct{}
+
+// Features implements Store interface
+func (f failingStore) Features() (map[string]config.Feature, error) {
+	return nil, errors.New("store is unavailable")
+}
+
+// TestFlags
+func TestFlags(t *testing.T) {
+	flags := New(map[string]config.Feature{
+		"Global":     {Enabled: true},
+		"new_search": {Percentage: 30, Users: []string{"alice"}},
+		"site_quota": {Percentage: 50, Key: "site", Sites: []string{"T1_US"}},
+	})
+	if !flags.Enabled("global", Subject{}) || flags.Enabled("unknown", Subject{User: "alice"}) {
+		t.Error("wrong evaluation of global and unknown features")
+	}
+	if !flags.Enabled("new_search", Subject{User: "alice"}) || !flags.Enabled("site_quota", Subject{Site: "T1_US"}) {
+		t.Error("listed user or site is not enabled")
+	}
+	if flags.Enabled("new_search", Subject{}) {
+		t.Error("anonymous subject is enabled in percentage rollout")
+	}
+
+	// percentage rollout is stable and close to requested percentage
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		user := fmt.Sprintf("user%d", i)
+		val := flags.Enabled("new_search", Subject{User: user})
+		if val != flags.Enabled("new_search", Subject{User: user, Site: "T2_CH"}) {
+			t.Fatalf("rollout of %s is not stable", user)
+		}
+		if val {
+			enabled++
+		}
+	}
+	if enabled < 250 || enabled > 350 {
+		t.Errorf("wrong rollout percentage %d of 1000", enabled)
+	}
+
+	// store flags override configuration ones
+	flags.Store = fakeStore{"global": {Enabled: false}, "beta": {Enabled: true}}
+	if err := flags.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if flags.Enabled("global", Subject{}) || !flags.Enabled("beta", Subject{}) {
+		t.Error("store flags are not applied")
+	}
+	flags.Set(map[string]config.Feature{"global": {Enabled: true}})
+	if flags.Enabled("global", Subject{}) || !flags.Enabled("beta", Subject{}) || flags.Enabled("new_search", Subject{User: "alice"}) {
+		t.Errorf("wrong flags after configuration change %v", flags.Names())
+	}
+
+	// flags are kept if store fails
+	flags.Store = failingStore{}
+	if err := flags.Refresh(); err == nil {
+		t.Error("store failure is not reported")
+	}
+	if !flags.Enabled("beta", Subject{}) {
+		t.Error("store flags are lost after store failure")
+	}
+}
+
+// TestFromConfig
+func TestFromConfig(t *testing.T) {
+	flags, stop := FromConfig()
+	config.Update(&config.OreCastConfig{Features: map[string]config.Feature{"beta": {Enabled: true}}})
+	if !flags.Enabled("beta", Subject{}) {
+		t.Error("configuration reload is not applied")
+	}
+	stop()
+	config.Update(&config.OreCastConfig{})
+	if !flags.Enabled("beta", Subject{}) {
+		t.Error("configuration reload is applied after stop")
+	}
+}
+
+// TestMiddleware
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	flags := New(map[string]config.Feature{"new_search": {Users: []string{"alice"}}})
+	var seen map[string]bool
+	router := gin.New()
+	router.Use(flags.Middleware(func(c *gin.Context) Subject {
+		return Subject{User: c.Query("user")}
+	}))
+	router.GET("/search", func(c *gin.Context) {
+		Enabled(c, "new_search")
+		Enabled(c, "old_search")
+		seen = Seen(c)
+		c.Status(http.StatusOK)
+	})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/search?user=alice", nil))
+	if len(seen) != 2 || !seen["new_search"] || seen["old_search"] {
+		t.Errorf("wrong seen features %v", seen)
+	}
+	if s := formatSeen(seen); s != "new_search=on,old_search=off" {
+		t.Errorf("wrong format of seen features %s", s)
+	}
+}
@@ -0,0 +1,96 @@
+package features
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// context keys of feature flags
+const (
+	flagsKey   = "features.flags"
+	subjectKey = "features.subject"
+	seenKey    = "features.seen"
+)
+
+// SubjectFunc returns subject of HTTP request
+type SubjectFunc func(c *gin.Context) Subject
+
+// seen holds features evaluated during request
+type seen struct {
+	mutex sync.Mutex
+	flags map[string]bool
+}
+
+// Middleware provides feature flags to request handlers and records flags
+// evaluated during request, subject function defines user and site of the
+// request. Recorded flags are available via Seen function and logged when
+// verbose level is set.
+func (f *Flags) Middleware(subject SubjectFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var s Subject
+		if subject != nil {
+			s = subject(c)
+		}
+		rec := &seen{flags: make(map[string]bool)}
+		c.Set(flagsKey, f)
+		c.Set(subjectKey, s)
+		c.Set(seenKey, rec)
+		c.Next()
+		if f.Verbose > 0 && len(rec.flags) > 0 {
+			log.Printf("%s %s features %s", c.Request.Method, c.Request.URL.Path, formatSeen(Seen(c)))
+		}
+	}
+}
+
+// Enabled reports if given feature is enabled for subject of the request and
+// records it, it requires Middleware
+func Enabled(c *gin.Context, name string) bool {
+	val, ok := c.Get(flagsKey)
+	if !ok {
+		return false
+	}
+	f := val.(*Flags)
+	s, _ := c.Get(subjectKey)
+	enabled := f.Enabled(name, s.(Subject))
+	if val, ok := c.Get(seenKey); ok {
+		rec := val.(*seen)
+		rec.mutex.Lock()
+		rec.flags[strings.ToLower(name)] = enabled
+		rec.mutex.Unlock()
+	}
+	return enabled
+}
+
+// Seen returns features evaluated during the request and their values
+func Seen(c *gin.Context) map[string]bool {
+	out := make(map[string]bool)
+	val, ok := c.Get(seenKey)
+	if !ok {
+		return out
+	}
+	rec := val.(*seen)
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	for name, enabled := range rec.flags {
+		out[name] = enabled
+	}
+	return out
+}
+
+// helper function to format seen features as name=on|off list
+func formatSeen(flags map[string]bool) string {
+	var out []string
+	for name, enabled := range flags {
+		val := "off"
+		if enabled {
+			val = "on"
+		}
+		out = append(out, name+"="+val)
+	}
+	sort.Strings(out)
+	return strings.Join(out, ",")
+}
@@ -0,0 +1,215 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BulkBatchSize defines default number of records sent in single bulk write
+var BulkBatchSize = 1000
+
+// ErrPartialFailure is returned by bulk operations when some records failed,
+// failed records are listed in BulkResult
+var ErrPartialFailure = errors.New("some records failed")
+
+// BulkOptions represents options of bulk operations
+type BulkOptions struct {
+	Ordered   bool // stop on first failed record
+	BatchSize int  // number of records per bulk write, BulkBatchSize by default
+}
+
+// BulkFailure describes failed record of bulk operation
+type BulkFailure struct {
+	Index   int    `json:"index"`   // index of the record in given list
+	Code    int    `json:"code"`    // MongoDB error code, zero for client side errors
+	Message string `json:"message"` // error message
+}
+
+// BulkResult represents result of bulk operation
+type BulkResult struct {
+	Inserted int64         `json:"inserted"` // number of inserted records
+	Matched  int64         `json:"matched"`  // number of matched records
+	Modified int64         `json:"modified"` // number of modified records
+	Upserted int64         `json:"upserted"` // number of upserted records
+	Deleted  int64         `json:"deleted"`  // number of deleted records
+	Skipped  int           `json:"skipped"`  // records not processed in ordered mode
+	Failures []BulkFailure `json:"failures"` // failed records
+}
+
+// helper function to add result of bulk write
+func (r *BulkResult) add(res *mongo.BulkWriteResult) {
+	if res == nil {
+		return
+	}
+	r.Inserted += res.InsertedCount
+	r.Matched += res.MatchedCount
+	r.Modified += res.ModifiedCount
+	r.Upserted += res.UpsertedCount
+	r.Deleted += res.DeletedCount
+}
+
+// BulkInsert inserts records with bulk writes
+func (m *Connection) BulkInsert(ctx context.Context, dbname, collname string, records []Record, opts BulkOptions) (*BulkResult, error) {
+	return m.bulkInsert(ctx, dbname, collname, records, nil, opts)
+}
+
+// helper function to insert records with bulk writes, records listed in
+// given client side failures are not written
+func (m *Connection) bulkInsert(ctx context.Context, dbname, collname string, records []Record, failures []BulkFailure, opts BulkOptions) (*BulkResult, error) {
+	var models []mongo.WriteModel
+	var index []int
+	for i, rec := range records {
+		if failed(failures, i) {
+			continue
+		}
+		models = append(models, mongo.NewInsertOneModel().SetDocument(rec))
+		index = append(index, i)
+	}
+	return m.bulkWrite(ctx, "bulk insert", dbname, collname, models, index, failures, opts)
+}
+
+// BulkUpsert updates or inserts records identified by given attribute with
+// bulk writes, records without attribute value are reported as failures
+func (m *Connection) BulkUpsert(ctx context.Context, dbname, collname, attr string, records []Record, opts BulkOptions) (*BulkResult, error) {
+	return m.bulkUpsert(ctx, dbname, collname, attr, records, nil, opts)
+}
+
+// helper function to upsert records with bulk writes, records listed in
+// given client side failures are not written
+func (m *Connection) bulkUpsert(ctx context.Context, dbname, collname, attr string, records []Record, failures []BulkFailure, opts BulkOptions) (*BulkResult, error) {
+	var models []mongo.WriteModel
+	var index []int
+	for i, rec := range records {
+		if failed(failures, i) {
+			continue
+		}
+		value, ok := rec[attr]
+		if !ok || value == "" {
+			failures = append(failures, BulkFailure{Index: i, Message: fmt.Sprintf("record has no %s value", attr)})
+			continue
+		}
+		model := mongo.NewUpdateOneModel().
+			SetFilter(bson.M{attr: value}).
+			SetUpdate(bson.D{{Key: "$set", Value: rec}}).
+			SetUpsert(true)
+		models = append(models, model)
+		index = append(index, i)
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Index < failures[j].Index })
+	return m.bulkWrite(ctx, "bulk upsert", dbname, collname, models, index, failures, opts)
+}
+
+// helper function to check if record with given index is listed in failures
+func failed(failures []BulkFailure, idx int) bool {
+	for _, f := range failures {
+		if f.Index == idx {
+			return true
+		}
+	}
+	return false
+}
+
+// BulkDelete removes records matching given specs with bulk writes
+func (m *Connection) BulkDelete(ctx context.Context, dbname, collname string, specs []bson.M, opts BulkOptions) (*BulkResult, error) {
+	var models []mongo.WriteModel
+	var index []int
+	for i, spec := range specs {
+		models = append(models, mongo.NewDeleteManyModel().SetFilter(spec))
+		index = append(index, i)
+	}
+	return m.bulkWrite(ctx, "bulk delete", dbname, collname, models, index, nil, opts)
+}
+
+// BulkInsert inserts records with bulk writes
+func BulkInsert(ctx context.Context, dbname, collname string, records []Record, opts BulkOptions) (*BulkResult, error) {
+	return Mongo.BulkInsert(ctx, dbname, collname, records, opts)
+}
+
+// BulkUpsert updates or inserts records identified by given attribute with
+// bulk writes, records without attribute value are reported as failures
+func BulkUpsert(ctx context.Context, dbname, collname, attr string, records []Record, opts BulkOptions) (*BulkResult, error) {
+	return Mongo.BulkUpsert(ctx, dbname, collname, attr, records, opts)
+}
+
+// BulkDelete removes records matching given specs with bulk writes
+func BulkDelete(ctx context.Context, dbname, collname string, specs []bson.M, opts BulkOptions) (*BulkResult, error) {
+	return Mongo.BulkDelete(ctx, dbname, collname, specs, opts)
+}
+
+// helper function to perform bulk writes of given models in batches, index
+// maps models to indexes of records given by caller. In ordered mode records
+// before first client side failure are written and the rest are skipped.
+func (m *Connection) bulkWrite(ctx context.Context, op, dbname, collname string, models []mongo.WriteModel, index []int, failures []BulkFailure, opts BulkOptions) (*BulkResult, error) {
+	result := &BulkResult{}
+	var halt *BulkFailure // client side failure which stops ordered operation
+	pending := 0          // records which follow models in ordered mode
+	if opts.Ordered && len(failures) > 0 {
+		halt = &failures[0]
+		n := 0
+		for n < len(index) && index[n] < halt.Index {
+			n++
+		}
+		pending = len(models) - n + len(failures)
+		models, index = models[:n], index[:n]
+	} else {
+		result.Failures = failures
+	}
+	size := opts.BatchSize
+	if size <= 0 {
+		size = BulkBatchSize
+	}
+	if len(models) > 0 {
+		c, err := m.collection(ctx, op, dbname, collname)
+		if err != nil {
+			result.Skipped = len(models) + pending
+			return result, err
+		}
+		wopts := options.BulkWrite().SetOrdered(opts.Ordered)
+		for start := 0; start < len(models); start += size {
+			end := start + size
+			if end > len(models) {
+				end = len(models)
+			}
+			res, err := c.BulkWrite(ctx, models[start:end], wopts)
+			result.add(res)
+			if err == nil {
+				continue
+			}
+			var bwe mongo.BulkWriteException
+			if !errors.As(err, &bwe) || bwe.WriteConcernError != nil {
+				result.Skipped = len(models) - start + pending
+				return result, newError(op, dbname, collname, err)
+			}
+			last := -1
+			for _, we := range bwe.WriteErrors {
+				result.Failures = append(result.Failures, BulkFailure{
+					Index:   index[start+we.Index],
+					Code:    we.Code,
+					Message: we.Message,
+				})
+				if we.Index > last {
+					last = we.Index
+				}
+			}
+			if opts.Ordered {
+				result.Skipped = len(models) - (start + last + 1) + pending
+				return result, newError(op, dbname, collname, ErrPartialFailure)
+			}
+		}
+	}
+	if halt != nil {
+		// failed record is reported, following records are not attempted
+		result.Failures = append(result.Failures, *halt)
+		result.Skipped = pending - 1
+	}
+	if len(result.Failures) > 0 {
+		return result, newError(op, dbname, collname, ErrPartialFailure)
+	}
+	return result, nil
+}
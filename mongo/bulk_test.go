@@ -0,0 +1,101 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/OreCast/common/data"
+)
+
+// TestBulkUpsertValidation
+func TestBulkUpsertValidation(t *testing.T) {
+	// ordered operation stops on first failed record before any write
+	records := []Record{{"name": "x"}, {"dataset": "/a/b/c"}, {"dataset": ""}}
+	res, err := BulkUpsert(context.Background(), "db", "coll", "dataset", records, BulkOptions{Ordered: true})
+	if !errors.Is(err, ErrPartialFailure) {
+		t.Errorf("wrong error %v", err)
+	}
+	if len(res.Failures) != 1 || res.Failures[0].Index != 0 || res.Skipped != 2 {
+		t.Errorf("wrong result %+v", res)
+	}
+
+	// records before failed one are written in ordered mode
+	defer InitMongoDB(Mongo.URI, Mongo.Options)
+	InitMongoDB("mongodb://127.0.0.1:1/?serverSelectionTimeoutMS=200&connectTimeoutMS=200")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	records = []Record{{"dataset": "/a/b/c"}, {"name": "x"}, {"dataset": ""}}
+	res, err = BulkUpsert(ctx, "db", "coll", "dataset", records, BulkOptions{Ordered: true})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("records before failed one are not written, error %v", err)
+	}
+	if len(res.Failures) != 0 || res.Skipped != 3 {
+		t.Errorf("wrong result %+v", res)
+	}
+
+	// unordered operation reports all failed records
+	res, err = BulkUpsert(ctx, "db", "coll", "dataset", records[1:], BulkOptions{})
+	if !errors.Is(err, ErrPartialFailure) {
+		t.Errorf("wrong error %v", err)
+	}
+	if len(res.Failures) != 2 || res.Failures[0].Index != 0 || res.Failures[1].Index != 1 || res.Skipped != 0 {
+		t.Errorf("wrong result %+v", res)
+	}
+}
+
+// TestBulkUnavailable
+func TestBulkUnavailable(t *testing.T) {
+	defer InitMongoDB(Mongo.URI, Mongo.Options)
+	InitMongoDB("mongodb://127.0.0.1:1/?serverSelectionTimeoutMS=200&connectTimeoutMS=200")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	records := []Record{{"dataset": "/a"}, {"dataset": "/b"}, {"dataset": "/c"}}
+	res, err := BulkInsert(ctx, "db", "coll", records, BulkOptions{BatchSize: 2})
+	if !errors.Is(err, ErrUnavailable) || errors.Is(err, ErrPartialFailure) {
+		t.Errorf("wrong error %v", err)
+	}
+	if res.Skipped != 3 || res.Inserted != 0 {
+		t.Errorf("wrong result %+v", res)
+	}
+}
+
+// TestCollectionBulkUpsert
+func TestCollectionBulkUpsert(t *testing.T) {
+	coll := SiteCollection("db", "sites")
+	res, err := coll.BulkUpsert(context.Background(), BulkOptions{}, data.Site{}, data.Site{})
+	if !errors.Is(err, ErrPartialFailure) && !errors.Is(err, ErrUnavailable) {
+		t.Errorf("wrong error %v", err)
+	}
+	if len(res.Failures) != 2 || res.Failures[1].Index != 1 {
+		t.Errorf("wrong result %+v", res)
+	}
+}
+
+// TestCollectionBulkConversion
+func TestCollectionBulkConversion(t *testing.T) {
+	conn := NewConnection("mongodb://127.0.0.1:1", Options{ServerSelectionTimeout: 200 * time.Millisecond})
+	defer conn.Close(context.Background())
+	coll := NewCollection[interface{}]("db", "sites", "name").WithConnection(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// record which can not be converted stops ordered operation
+	res, err := coll.BulkInsert(ctx, BulkOptions{Ordered: true}, 42, Record{"name": "a"})
+	if !errors.Is(err, ErrPartialFailure) || conn.Client != nil {
+		t.Errorf("wrong error %v", err)
+	}
+	if len(res.Failures) != 1 || res.Failures[0].Index != 0 || res.Skipped != 1 {
+		t.Errorf("wrong result %+v", res)
+	}
+
+	// converted records are written
+	res, err = coll.BulkUpsert(ctx, BulkOptions{}, Record{"name": "a"}, 42)
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("converted records are not written, error %v", err)
+	}
+	if len(res.Failures) != 1 || res.Failures[0].Index != 1 {
+		t.Errorf("wrong result %+v", res)
+	}
+}
@@ -0,0 +1,210 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/OreCast/common/data"
+	bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Collection provides typed access to MongoDB collection, records are
+// converted according to bson tags of T type, e.g.
+//
+//	sites := mongo.SiteCollection("OreCast", "sites")
+//	site, err := sites.FindOne(ctx, bson.M{"name": name})
+//
+// Collection uses default Mongo connection unless other connection is
+// given via WithConnection.
+type Collection[T any] struct {
+	DBName string      // database name
+	Name   string      // collection name
+	Key    string      // attribute which identifies records, used by Upsert
+	Conn   *Connection // MongoDB connection, Mongo if not set
+}
+
+// NewCollection creates typed collection
+func NewCollection[T any](dbname, collname, key string) *Collection[T] {
+	return &Collection[T]{DBName: dbname, Name: collname, Key: key}
+}
+
+// MetaDataCollection returns collection of meta-data records identified by id
+func MetaDataCollection(dbname, collname string) *Collection[data.MetaData] {
+	return NewCollection[data.MetaData](dbname, collname, "id")
+}
+
+// SiteCollection returns collection of site records identified by name
+func SiteCollection(dbname, collname string) *Collection[data.Site] {
+	return NewCollection[data.Site](dbname, collname, "name")
+}
+
+// WithConnection returns copy of the collection which uses given connection
+func (c *Collection[T]) WithConnection(conn *Connection) *Collection[T] {
+	out := *c
+	out.Conn = conn
+	return &out
+}
+
+// helper function to return connection of the collection
+func (c *Collection[T]) conn() *Connection {
+	if c.Conn != nil {
+		return c.Conn
+	}
+	return &Mongo
+}
+
+// Find returns records matching given spec, records are skipped up to idx
+// and limited to given limit if it is positive
+func (c *Collection[T]) Find(ctx context.Context, spec bson.M, idx, limit int) ([]T, error) {
+	out := []T{}
+	coll, err := c.conn().collection(ctx, "find", c.DBName, c.Name)
+	if err != nil {
+		return out, err
+	}
+	opts := options.Find().SetSkip(int64(idx))
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cur, err := coll.Find(ctx, spec, opts)
+	if err != nil {
+		return out, newError("find", c.DBName, c.Name, err)
+	}
+	if err := cur.All(ctx, &out); err != nil {
+		return out, newError("find", c.DBName, c.Name, err)
+	}
+	return out, nil
+}
+
+// FindOne returns single record matching given spec, it returns ErrNotFound
+// error if there is no such record
+func (c *Collection[T]) FindOne(ctx context.Context, spec bson.M) (T, error) {
+	var rec T
+	coll, err := c.conn().collection(ctx, "find", c.DBName, c.Name)
+	if err != nil {
+		return rec, err
+	}
+	if err := coll.FindOne(ctx, spec).Decode(&rec); err != nil {
+		return rec, newError("find", c.DBName, c.Name, err)
+	}
+	return rec, nil
+}
+
+// Insert inserts given records
+func (c *Collection[T]) Insert(ctx context.Context, records ...T) error {
+	if len(records) == 0 {
+		return nil
+	}
+	coll, err := c.conn().collection(ctx, "insert", c.DBName, c.Name)
+	if err != nil {
+		return err
+	}
+	docs := make([]interface{}, len(records))
+	for i := range records {
+		docs[i] = records[i]
+	}
+	if _, err := coll.InsertMany(ctx, docs); err != nil {
+		return newError("insert", c.DBName, c.Name, err)
+	}
+	return nil
+}
+
+// Upsert updates or inserts given records identified by collection key
+func (c *Collection[T]) Upsert(ctx context.Context, records ...T) error {
+	coll, err := c.conn().collection(ctx, "upsert", c.DBName, c.Name)
+	if err != nil {
+		return err
+	}
+	for _, rec := range records {
+		doc, err := toDocument(rec)
+		if err != nil {
+			return newError("upsert", c.DBName, c.Name, err)
+		}
+		value, ok := doc[c.Key]
+		if !ok || value == "" {
+			return newError("upsert", c.DBName, c.Name, fmt.Errorf("record has no %s value", c.Key))
+		}
+		spec := bson.M{c.Key: value}
+		update := bson.D{{Key: "$set", Value: doc}}
+		if _, err := coll.UpdateOne(ctx, spec, update, options.Update().SetUpsert(true)); err != nil {
+			return newError("upsert", c.DBName, c.Name, err)
+		}
+	}
+	return nil
+}
+
+// BulkInsert inserts given records with bulk writes, see BulkInsert,
+// records which can not be converted are reported as failures
+func (c *Collection[T]) BulkInsert(ctx context.Context, opts BulkOptions, records ...T) (*BulkResult, error) {
+	docs, failures := c.bulkDocuments(records)
+	return c.conn().bulkInsert(ctx, c.DBName, c.Name, docs, failures, opts)
+}
+
+// BulkUpsert updates or inserts given records identified by collection key
+// with bulk writes, see BulkUpsert, records which can not be converted are
+// reported as failures
+func (c *Collection[T]) BulkUpsert(ctx context.Context, opts BulkOptions, records ...T) (*BulkResult, error) {
+	docs, failures := c.bulkDocuments(records)
+	return c.conn().bulkUpsert(ctx, c.DBName, c.Name, c.Key, docs, failures, opts)
+}
+
+// helper function to convert records into MongoDB documents, it returns
+// documents in order of records and conversion failures
+func (c *Collection[T]) bulkDocuments(records []T) ([]Record, []BulkFailure) {
+	docs := make([]Record, len(records))
+	var failures []BulkFailure
+	for i, rec := range records {
+		doc, err := toDocument(rec)
+		if err != nil {
+			failures = append(failures, BulkFailure{Index: i, Message: err.Error()})
+			continue
+		}
+		docs[i] = Record(doc)
+	}
+	return docs, failures
+}
+
+// Update updates records matching given spec with update document, e.g.
+// bson.M{"$set": bson.M{"description": "new description"}}, it returns
+// number of modified records
+func (c *Collection[T]) Update(ctx context.Context, spec, update bson.M) (int64, error) {
+	coll, err := c.conn().collection(ctx, "update", c.DBName, c.Name)
+	if err != nil {
+		return 0, err
+	}
+	res, err := coll.UpdateMany(ctx, spec, update)
+	if err != nil {
+		return 0, newError("update", c.DBName, c.Name, err)
+	}
+	return res.ModifiedCount, nil
+}
+
+// Delete removes records matching given spec, it returns number of
+// removed records
+func (c *Collection[T]) Delete(ctx context.Context, spec bson.M) (int64, error) {
+	coll, err := c.conn().collection(ctx, "remove", c.DBName, c.Name)
+	if err != nil {
+		return 0, err
+	}
+	res, err := coll.DeleteMany(ctx, spec)
+	if err != nil {
+		return 0, newError("remove", c.DBName, c.Name, err)
+	}
+	return res.DeletedCount, nil
+}
+
+// Count returns number of records matching given spec
+func (c *Collection[T]) Count(ctx context.Context, spec bson.M) (int, error) {
+	return c.conn().CountContext(ctx, c.DBName, c.Name, spec)
+}
+
+// helper function to convert record into MongoDB document
+func toDocument(rec interface{}) (bson.M, error) {
+	data, err := bson.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	err = bson.Unmarshal(data, &doc)
+	return doc, err
+}
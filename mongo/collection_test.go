@@ -0,0 +1,44 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/OreCast/common/data"
+	bson "go.mongodb.org/mongo-driver/bson"
+)
+
+// TestCollection
+func TestCollection(t *testing.T) {
+	meta := data.MetaData{ID: "123", Site: "T1_US", Tags: []string{"a", "b"}}
+	doc, err := toDocument(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc["id"] != "123" || doc["site"] != "T1_US" {
+		t.Errorf("wrong document of meta-data record %v", doc)
+	}
+	site := data.Site{Name: "T1_US", UseSSL: true}
+	doc, err = toDocument(site)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc["name"] != "T1_US" || doc["use_ssl"] != true {
+		t.Errorf("wrong document of site record %v", doc)
+	}
+
+	// operations report unavailable database
+	defer InitMongoDB(Mongo.URI, Mongo.Options)
+	InitMongoDB("mongodb://127.0.0.1:1/?serverSelectionTimeoutMS=200&connectTimeoutMS=200")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sites := SiteCollection("OreCast", "sites")
+	if _, err := sites.FindOne(ctx, bson.M{"name": "T1_US"}); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("unavailable database is not reported, error %v", err)
+	}
+	if err := sites.Upsert(ctx, data.Site{}); err == nil {
+		t.Error("record without key is upserted")
+	}
+}
@@ -0,0 +1,397 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Connection defines connection to MongoDB
+type Connection struct {
+	Client  *mongo.Client
+	URI     string
+	Options Options
+	mu      sync.Mutex
+}
+
+// NewConnection creates MongoDB connection object with optional connection
+// options, the connection is established on first use
+func NewConnection(uri string, opts ...Options) *Connection {
+	m := &Connection{URI: uri}
+	if len(opts) > 0 {
+		m.Options = opts[0]
+	}
+	return m
+}
+
+// Connect provides connection to MongoDB
+//
+// Deprecated: use ConnectContext instead.
+func (m *Connection) Connect() (*mongo.Client, error) {
+	return m.ConnectContext(context.Background())
+}
+
+// ConnectContext provides connection to MongoDB, the connection is
+// established once and reused until Close is called
+func (m *Connection) ConnectContext(ctx context.Context) (*mongo.Client, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Client != nil {
+		return m.Client, nil
+	}
+	opts, err := m.Options.clientOptions(m.URI)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ConnectTimeout)
+		defer cancel()
+	}
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	m.Client = client
+	return client, nil
+}
+
+// Ping checks that MongoDB server is reachable, it can be used as health
+// check of the service. The client is kept on failures since it is shared
+// by concurrent operations and reconnects to the server by itself, use
+// Reconnect to replace it explicitly.
+func (m *Connection) Ping(ctx context.Context) error {
+	client, err := m.ConnectContext(ctx)
+	if err != nil {
+		return newError("ping", "", "", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		return newError("ping", "", "", err)
+	}
+	return nil
+}
+
+// Reconnect disconnects current client and establishes new connection
+func (m *Connection) Reconnect(ctx context.Context) error {
+	m.mu.Lock()
+	client := m.Client
+	m.mu.Unlock()
+	if client != nil {
+		m.drop(ctx, client)
+	}
+	if _, err := m.ConnectContext(ctx); err != nil {
+		return newError("connect", "", "", err)
+	}
+	return nil
+}
+
+// helper function to drop given client unless it was already replaced
+func (m *Connection) drop(ctx context.Context, client *mongo.Client) {
+	m.mu.Lock()
+	if m.Client != client {
+		m.mu.Unlock()
+		return
+	}
+	m.Client = nil
+	m.mu.Unlock()
+	if err := client.Disconnect(ctx); err != nil {
+		log.Println("WARNING: unable to disconnect MongoDB client", err)
+	}
+}
+
+// Close disconnects from MongoDB, subsequent operations establish new
+// connection
+func (m *Connection) Close(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Client == nil {
+		return nil
+	}
+	err := m.Client.Disconnect(ctx)
+	m.Client = nil
+	return newError("close", "", "", err)
+}
+
+// Reset changes connection parameters, e.g. on configuration reload, and
+// subsequent operations establish new connection. Current client is
+// disconnected after DisconnectDelay to let in-flight operations finish,
+// errors of the disconnect are logged.
+func (m *Connection) Reset(ctx context.Context, uri string, opts ...Options) error {
+	m.mu.Lock()
+	client := m.Client
+	m.Client = nil
+	m.URI = uri
+	m.Options = Options{}
+	if len(opts) > 0 {
+		m.Options = opts[0]
+	}
+	m.mu.Unlock()
+	if client != nil {
+		time.AfterFunc(DisconnectDelay, func() {
+			ctx, cancel := context.WithTimeout(context.Background(), ConnectTimeout)
+			defer cancel()
+			if err := client.Disconnect(ctx); err != nil {
+				log.Println("WARNING: unable to disconnect MongoDB client", err)
+			}
+		})
+	}
+	return nil
+}
+
+// helper function to get MongoDB collection
+func (m *Connection) collection(ctx context.Context, op, dbname, collname string) (*mongo.Collection, error) {
+	client, err := m.ConnectContext(ctx)
+	if err != nil {
+		return nil, newError(op, dbname, collname, err)
+	}
+	return client.Database(dbname).Collection(collname), nil
+}
+
+// InsertContext inserts records into MongoDB
+func (m *Connection) InsertContext(ctx context.Context, dbname, collname string, records []Record) error {
+	c, err := m.collection(ctx, "insert", dbname, collname)
+	if err != nil {
+		return err
+	}
+	for _, rec := range records {
+		if _, err := c.InsertOne(ctx, &rec); err != nil {
+			return newError("insert", dbname, collname, err)
+		}
+	}
+	return nil
+}
+
+// UpsertContext updates or inserts records identified by given attribute,
+// records without string attribute value are reported before any write
+func (m *Connection) UpsertContext(ctx context.Context, dbname, collname, attr string, records []Record) error {
+	for i, rec := range records {
+		if value, ok := rec[attr].(string); !ok || value == "" {
+			return newError("upsert", dbname, collname, fmt.Errorf("record %d has no %s value", i, attr))
+		}
+	}
+	c, err := m.collection(ctx, "upsert", dbname, collname)
+	if err != nil {
+		return err
+	}
+	for _, rec := range records {
+		spec := bson.M{attr: rec[attr]}
+		update := bson.D{{Key: "$set", Value: rec}}
+		opts := options.Update().SetUpsert(true)
+		if _, err := c.UpdateOne(ctx, spec, update, opts); err != nil {
+			return newError("upsert", dbname, collname, err)
+		}
+	}
+	return nil
+}
+
+// GetContext returns records matching given spec, records are skipped up
+// to idx and limited to given limit if it is positive
+func (m *Connection) GetContext(ctx context.Context, dbname, collname string, spec bson.M, idx, limit int) ([]Record, error) {
+	out := []Record{}
+	c, err := m.collection(ctx, "find", dbname, collname)
+	if err != nil {
+		return out, err
+	}
+	opts := options.Find().SetSkip(int64(idx))
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cur, err := c.Find(ctx, spec, opts)
+	if err != nil {
+		return out, newError("find", dbname, collname, err)
+	}
+	if err := cur.All(ctx, &out); err != nil {
+		return out, newError("find", dbname, collname, err)
+	}
+	return out, nil
+}
+
+// GetOneContext returns single record matching given spec, it returns
+// ErrNotFound error if there is no such record
+func (m *Connection) GetOneContext(ctx context.Context, dbname, collname string, spec bson.M) (Record, error) {
+	c, err := m.collection(ctx, "find", dbname, collname)
+	if err != nil {
+		return nil, err
+	}
+	var rec Record
+	if err := c.FindOne(ctx, spec).Decode(&rec); err != nil {
+		return nil, newError("find", dbname, collname, err)
+	}
+	return rec, nil
+}
+
+// GetSortedContext returns records matching given spec sorted by given keys,
+// keys with "-" prefix are sorted in descending order
+func (m *Connection) GetSortedContext(ctx context.Context, dbname, collname string, spec bson.M, skeys []string) ([]Record, error) {
+	return m.GetSortedRangeContext(ctx, dbname, collname, spec, skeys, 0, 0)
+}
+
+// GetSortedRangeContext returns records matching given spec sorted by given
+// keys, records are skipped up to idx and limited to given limit if it is
+// positive, keys with "-" prefix are sorted in descending order
+func (m *Connection) GetSortedRangeContext(ctx context.Context, dbname, collname string, spec bson.M, skeys []string, idx, limit int) ([]Record, error) {
+	out := []Record{}
+	c, err := m.collection(ctx, "find", dbname, collname)
+	if err != nil {
+		return out, err
+	}
+	sortSpec := bson.D{}
+	for _, s := range skeys {
+		if strings.HasPrefix(s, "-") {
+			sortSpec = append(sortSpec, bson.E{Key: s[1:], Value: -1})
+		} else {
+			sortSpec = append(sortSpec, bson.E{Key: s, Value: 1})
+		}
+	}
+	opts := options.Find().SetSort(sortSpec).SetSkip(int64(idx))
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cur, err := c.Find(ctx, spec, opts)
+	if err != nil {
+		return out, newError("find", dbname, collname, err)
+	}
+	if err := cur.All(ctx, &out); err != nil {
+		return out, newError("find", dbname, collname, err)
+	}
+	return out, nil
+}
+
+// UpdateContext updates first record matching given spec
+func (m *Connection) UpdateContext(ctx context.Context, dbname, collname string, spec, newdata bson.M) error {
+	c, err := m.collection(ctx, "update", dbname, collname)
+	if err != nil {
+		return err
+	}
+	if _, err := c.UpdateOne(ctx, spec, newdata); err != nil {
+		return newError("update", dbname, collname, err)
+	}
+	return nil
+}
+
+// ModifyContext applies update operators (e.g. $set, $inc, $pull) to first
+// record matching given spec and returns number of matched records, it
+// allows conditional updates of individual fields
+func (m *Connection) ModifyContext(ctx context.Context, dbname, collname string, spec, update bson.M) (int, error) {
+	c, err := m.collection(ctx, "update", dbname, collname)
+	if err != nil {
+		return 0, err
+	}
+	res, err := c.UpdateOne(ctx, spec, update)
+	if err != nil {
+		return 0, newError("update", dbname, collname, err)
+	}
+	return int(res.MatchedCount), nil
+}
+
+// CountContext returns number of records matching given spec
+func (m *Connection) CountContext(ctx context.Context, dbname, collname string, spec bson.M) (int, error) {
+	c, err := m.collection(ctx, "count", dbname, collname)
+	if err != nil {
+		return 0, err
+	}
+	nrec, err := c.CountDocuments(ctx, spec)
+	if err != nil {
+		return 0, newError("count", dbname, collname, err)
+	}
+	return int(nrec), nil
+}
+
+// RemoveContext removes records matching given spec
+func (m *Connection) RemoveContext(ctx context.Context, dbname, collname string, spec bson.M) error {
+	c, err := m.collection(ctx, "remove", dbname, collname)
+	if err != nil {
+		return err
+	}
+	if _, err := c.DeleteMany(ctx, spec); err != nil {
+		return newError("remove", dbname, collname, err)
+	}
+	return nil
+}
+
+// CreateIndexContext creates index of given keys, e.g. unique index of
+// user logins, existing index with the same keys is kept
+func (m *Connection) CreateIndexContext(ctx context.Context, dbname, collname string, unique bool, keys ...string) error {
+	c, err := m.collection(ctx, "index", dbname, collname)
+	if err != nil {
+		return err
+	}
+	spec := bson.D{}
+	for _, key := range keys {
+		spec = append(spec, bson.E{Key: key, Value: 1})
+	}
+	model := mongo.IndexModel{Keys: spec, Options: options.Index().SetUnique(unique)}
+	if _, err := c.Indexes().CreateOne(ctx, model); err != nil {
+		return newError("index", dbname, collname, err)
+	}
+	return nil
+}
+
+// Insert records into MongoDB
+func (m *Connection) Insert(dbname, collname string, records []Record) {
+	if err := m.InsertContext(context.TODO(), dbname, collname, records); err != nil {
+		log.Printf("Fail to insert records, error %v\n", err)
+	}
+}
+
+// Upsert records into MongoDB
+func (m *Connection) Upsert(dbname, collname, attr string, records []Record) error {
+	err := m.UpsertContext(context.TODO(), dbname, collname, attr, records)
+	if err != nil {
+		log.Printf("Fail to upsert records, error %v\n", err)
+	}
+	return err
+}
+
+// Get records from MongoDB
+func (m *Connection) Get(dbname, collname string, spec bson.M, idx, limit int) []Record {
+	out, err := m.GetContext(context.TODO(), dbname, collname, spec, idx, limit)
+	if err != nil {
+		log.Printf("Unable to get records, spec=%+v, error %v\n", spec, err)
+	}
+	return out
+}
+
+// GetSorted records from MongoDB sorted by given key
+func (m *Connection) GetSorted(dbname, collname string, spec bson.M, skeys []string) []Record {
+	out, err := m.GetSortedContext(context.TODO(), dbname, collname, spec, skeys)
+	if err != nil {
+		log.Printf("Unable to sort records, error %v\n", err)
+		var e *Error
+		if errors.As(err, &e) {
+			out = append(out, ErrorRecord(fmt.Sprintf("%v", err), DBErrorName, e.Code()))
+		}
+	}
+	return out
+}
+
+// Update inplace for given spec
+func (m *Connection) Update(dbname, collname string, spec, newdata bson.M) {
+	if err := m.UpdateContext(context.TODO(), dbname, collname, spec, newdata); err != nil {
+		log.Printf("Unable to update record, spec %v, data %v, error %v\n", spec, newdata, err)
+	}
+}
+
+// Count gets number records from MongoDB
+func (m *Connection) Count(dbname, collname string, spec bson.M) int {
+	nrec, err := m.CountContext(context.TODO(), dbname, collname, spec)
+	if err != nil {
+		log.Printf("Unable to count records, spec %v, error %v\n", spec, err)
+	}
+	return nrec
+}
+
+// Remove records from MongoDB
+func (m *Connection) Remove(dbname, collname string, spec bson.M) {
+	if err := m.RemoveContext(context.TODO(), dbname, collname, spec); err != nil {
+		log.Printf("Unable to remove records, spec %v, error %v\n", spec, err)
+	}
+}
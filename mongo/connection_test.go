@@ -0,0 +1,132 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TestConnections
+func TestConnections(t *testing.T) {
+	defer InitMongoDB(Mongo.URI, Mongo.Options)
+	InitMongoDB("mongodb://localhost:8230")
+	opts := Options{ServerSelectionTimeout: 200 * time.Millisecond}
+	primary := NewConnection("mongodb://127.0.0.1:1", opts)
+	archive := NewConnection("mongodb://127.0.0.1:2", opts)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := primary.CountContext(ctx, "db", "coll", bson.M{}); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("unavailable database is not reported, error %v", err)
+	}
+	if _, err := archive.GetContext(ctx, "db", "coll", bson.M{}, 0, 0); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("unavailable database is not reported, error %v", err)
+	}
+	if primary.Client == nil || archive.Client == nil || primary.Client == archive.Client {
+		t.Fatal("connections are not independent")
+	}
+	if Mongo.Client != nil {
+		t.Error("default connection is used")
+	}
+	if err := primary.Close(ctx); err != nil || primary.Client != nil || archive.Client == nil {
+		t.Errorf("wrong connection is closed, error %v", err)
+	}
+	sites := SiteCollection("db", "sites").WithConnection(archive)
+	if _, err := sites.Count(ctx, bson.M{}); !errors.Is(err, ErrUnavailable) || Mongo.Client != nil {
+		t.Errorf("collection does not use given connection, error %v", err)
+	}
+	archive.Close(ctx)
+}
+
+// TestCreateIndexUnavailable
+func TestCreateIndexUnavailable(t *testing.T) {
+	conn := NewConnection("mongodb://127.0.0.1:1", Options{ServerSelectionTimeout: 200 * time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := conn.CreateIndexContext(ctx, "db", "users", true, "login"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("unavailable database is not reported, error %v", err)
+	}
+	conn.Close(ctx)
+}
+
+// TestGetSortedRangeUnavailable
+func TestGetSortedRangeUnavailable(t *testing.T) {
+	conn := NewConnection("mongodb://127.0.0.1:1", Options{ServerSelectionTimeout: 200 * time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := conn.GetSortedRangeContext(ctx, "db", "audit", bson.M{}, []string{"-timestamp"}, 0, 10); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("unavailable database is not reported, error %v", err)
+	}
+	conn.Close(ctx)
+}
+
+// TestReset
+func TestReset(t *testing.T) {
+	opts := Options{ServerSelectionTimeout: 200 * time.Millisecond}
+	conn := NewConnection("mongodb://127.0.0.1:1", opts)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	defer func(delay time.Duration) { DisconnectDelay = delay }(DisconnectDelay)
+	DisconnectDelay = 500 * time.Millisecond
+	client, err := conn.ConnectContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Reset(ctx, "mongodb://127.0.0.1:2", opts); err != nil {
+		t.Fatal(err)
+	}
+	if conn.Client != nil || conn.URI != "mongodb://127.0.0.1:2" {
+		t.Errorf("connection is not reset %+v", conn)
+	}
+	// replaced client serves in-flight operations until DisconnectDelay
+	if err := client.Ping(ctx, nil); errors.Is(err, mongo.ErrClientDisconnected) {
+		t.Error("replaced client is disconnected immediately")
+	}
+	time.Sleep(time.Second)
+	if err := client.Ping(ctx, nil); !errors.Is(err, mongo.ErrClientDisconnected) {
+		t.Errorf("replaced client is not disconnected, error %v", err)
+	}
+	conn.Close(ctx)
+}
+
+// TestReconnect
+func TestReconnect(t *testing.T) {
+	conn := NewConnection("mongodb://127.0.0.1:1", Options{ServerSelectionTimeout: 200 * time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := conn.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// failed ping keeps the client shared by other operations
+	if err := conn.Ping(ctx); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("unavailable database is not reported, error %v", err)
+	}
+	if conn.Client != client {
+		t.Error("client is dropped by failed ping")
+	}
+	if err := conn.Reconnect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if conn.Client == nil || conn.Client == client {
+		t.Error("new client is not established")
+	}
+	conn.Close(ctx)
+}
+
+// TestInitMongoDB
+func TestInitMongoDB(t *testing.T) {
+	defer InitMongoDB(Mongo.URI, Mongo.Options)
+	opts := Options{ServerSelectionTimeout: 200 * time.Millisecond}
+	InitMongoDB("mongodb://127.0.0.1:1", opts)
+	if _, err := Mongo.ConnectContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	InitMongoDB("mongodb://127.0.0.1:2", opts)
+	if Mongo.Client != nil || Mongo.URI != "mongodb://127.0.0.1:2" || Mongo.Options != opts {
+		t.Errorf("default connection is not reinitialized %+v", Mongo.URI)
+	}
+}
@@ -0,0 +1,81 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Errors of MongoDB operations, they are matched via errors.Is, e.g.
+//
+//	rec, err := mongo.GetOneContext(ctx, dbname, collname, spec)
+//	if errors.Is(err, mongo.ErrNotFound) {
+//		...
+//	}
+var (
+	ErrNotFound    = errors.New("record not found")
+	ErrDuplicate   = errors.New("duplicate record")
+	ErrUnavailable = errors.New("database is unavailable")
+)
+
+// Error represents error of MongoDB operation
+type Error struct {
+	Op         string // operation, e.g. insert
+	DBName     string // database name
+	Collection string // collection name
+	Err        error  // underlying error
+}
+
+// Error implements error interface
+func (e *Error) Error() string {
+	if e.DBName == "" && e.Collection == "" {
+		return fmt.Sprintf("mongo %s: %v", e.Op, e.Err)
+	}
+	return fmt.Sprintf("mongo %s %s.%s: %v", e.Op, e.DBName, e.Collection, e.Err)
+}
+
+// Unwrap returns underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches error with ErrNotFound, ErrDuplicate and ErrUnavailable errors
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return errors.Is(e.Err, mongo.ErrNoDocuments)
+	case ErrDuplicate:
+		return mongo.IsDuplicateKeyError(e.Err)
+	case ErrUnavailable:
+		return mongo.IsNetworkError(e.Err) || mongo.IsTimeout(e.Err) ||
+			errors.Is(e.Err, mongo.ErrClientDisconnected) || errors.Is(e.Err, context.Canceled)
+	}
+	return false
+}
+
+// Code returns code of the error used by ErrorRecord
+func (e *Error) Code() int {
+	switch {
+	case e.Is(ErrUnavailable):
+		return ServerError
+	case e.Is(ErrNotFound):
+		return QueryError
+	case e.Is(ErrDuplicate):
+		return ValidationError
+	}
+	return DBError
+}
+
+// helper function to wrap error of MongoDB operation
+func newError(op, dbname, collname string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+	return &Error{Op: op, DBName: dbname, Collection: collname, Err: err}
+}
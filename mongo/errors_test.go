@@ -0,0 +1,54 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TestErrors
+func TestErrors(t *testing.T) {
+	err := newError("find", "db", "coll", mongo.ErrNoDocuments)
+	if !errors.Is(err, ErrNotFound) || errors.Is(err, ErrUnavailable) {
+		t.Errorf("wrong classification of %v", err)
+	}
+	dup := mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: "E11000 duplicate key"}}}
+	err = newError("insert", "db", "coll", dup)
+	if !errors.Is(err, ErrDuplicate) || err.(*Error).Code() != ValidationError {
+		t.Errorf("wrong classification of %v", err)
+	}
+	if newError("insert", "db", "coll", err) != err {
+		t.Error("error is wrapped twice")
+	}
+}
+
+// TestUnavailable
+func TestUnavailable(t *testing.T) {
+	defer InitMongoDB(Mongo.URI, Mongo.Options)
+	InitMongoDB("mongodb://127.0.0.1:1/?serverSelectionTimeoutMS=200&connectTimeoutMS=200")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := GetContext(ctx, "db", "coll", bson.M{}, 0, 0)
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("unavailable database is not reported, error %v", err)
+	}
+	if _, err := CountContext(ctx, "db", "coll", bson.M{}); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("unavailable database is not reported, error %v", err)
+	}
+	if _, err := ModifyContext(ctx, "db", "coll", bson.M{}, bson.M{"$inc": bson.M{"n": 1}}); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("unavailable database is not reported, error %v", err)
+	}
+
+	// records without string key are reported before any write
+	for _, rec := range []Record{{"name": "a"}, {"dataset": 42}, {"dataset": ""}} {
+		records := []Record{{"dataset": "/a/b/c"}, rec}
+		err := UpsertContext(ctx, "db", "coll", "dataset", records)
+		if err == nil || errors.Is(err, ErrUnavailable) {
+			t.Errorf("record %v without key is not reported, error %v", rec, err)
+		}
+	}
+}
@@ -3,6 +3,7 @@ module github.com/OreCast/common/mongo
 go 1.21.3
 
 require (
+	github.com/OreCast/common/data v0.1.0
 	github.com/OreCast/common/utils v0.0.0-20231008113920-e5b3f8d8b2d9
 	go.mongodb.org/mongo-driver v1.12.1
 )
@@ -20,3 +21,5 @@ require (
 	golang.org/x/sync v0.0.0-20220722155255-886fb9371eb4 // indirect
 	golang.org/x/text v0.7.0 // indirect
 )
+
+replace github.com/OreCast/common/data => ../data
@@ -19,12 +19,9 @@ import (
 	"html"
 	"log"
 	"strings"
-	"time"
 
 	utils "github.com/OreCast/common/utils"
 	bson "go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const (
@@ -181,166 +178,130 @@ func GetInt64Value(rec Record, key string) (int64, error) {
 	return 0, fmt.Errorf("Unable to cast value for key '%s'", key)
 }
 
-// Connection defines connection to MongoDB
-type Connection struct {
-	Client *mongo.Client
-	URI    string
-}
+// Mongo holds default MongoDB connection used by package level functions
+var Mongo Connection
 
-// InitMongoDB initializes MongoDB connection object
-func InitMongoDB(uri string) {
-	Mongo = Connection{URI: uri}
+// InitMongoDB initializes MongoDB connection object with optional
+// connection options, existing connection is closed
+func InitMongoDB(uri string, opts ...Options) {
+	if err := Mongo.Reset(context.Background(), uri, opts...); err != nil {
+		log.Println("WARNING: unable to close MongoDB connection", err)
+	}
 }
 
-// Connect provides connection to MongoDB
-func (m *Connection) Connect() *mongo.Client {
-	var err error
-	if m.Client != nil {
-		return m.Client
-	}
-	client, err := mongo.NewClient(options.Client().ApplyURI(m.URI))
-	if err != nil {
-		log.Fatal(err)
-	}
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-	err = client.Connect(ctx)
-	if err != nil {
-		log.Fatal(err)
+// helper function to present in bson selected fields
+func sel(q ...string) (r bson.M) {
+	r = make(bson.M, len(q))
+	for _, s := range q {
+		r[s] = 1
 	}
-	m.Client = client
-	return client
+	return
 }
 
-// Mongo holds MongoDB connection
-var Mongo Connection
+// Ping checks that default MongoDB connection is alive
+func Ping(ctx context.Context) error {
+	return Mongo.Ping(ctx)
+}
+
+// Close disconnects default MongoDB connection
+func Close(ctx context.Context) error {
+	return Mongo.Close(ctx)
+}
+
+// InsertContext inserts records into MongoDB
+func InsertContext(ctx context.Context, dbname, collname string, records []Record) error {
+	return Mongo.InsertContext(ctx, dbname, collname, records)
+}
+
+// UpsertContext updates or inserts records identified by given attribute,
+// records without string attribute value are reported before any write
+func UpsertContext(ctx context.Context, dbname, collname, attr string, records []Record) error {
+	return Mongo.UpsertContext(ctx, dbname, collname, attr, records)
+}
+
+// GetContext returns records matching given spec, records are skipped up
+// to idx and limited to given limit if it is positive
+func GetContext(ctx context.Context, dbname, collname string, spec bson.M, idx, limit int) ([]Record, error) {
+	return Mongo.GetContext(ctx, dbname, collname, spec, idx, limit)
+}
+
+// GetOneContext returns single record matching given spec, it returns
+// ErrNotFound error if there is no such record
+func GetOneContext(ctx context.Context, dbname, collname string, spec bson.M) (Record, error) {
+	return Mongo.GetOneContext(ctx, dbname, collname, spec)
+}
+
+// GetSortedContext returns records matching given spec sorted by given keys,
+// keys with "-" prefix are sorted in descending order
+func GetSortedContext(ctx context.Context, dbname, collname string, spec bson.M, skeys []string) ([]Record, error) {
+	return Mongo.GetSortedContext(ctx, dbname, collname, spec, skeys)
+}
+
+// GetSortedRangeContext returns records matching given spec sorted by given
+// keys, records are skipped up to idx and limited to given limit if it is
+// positive, keys with "-" prefix are sorted in descending order
+func GetSortedRangeContext(ctx context.Context, dbname, collname string, spec bson.M, skeys []string, idx, limit int) ([]Record, error) {
+	return Mongo.GetSortedRangeContext(ctx, dbname, collname, spec, skeys, idx, limit)
+}
+
+// UpdateContext updates first record matching given spec
+func UpdateContext(ctx context.Context, dbname, collname string, spec, newdata bson.M) error {
+	return Mongo.UpdateContext(ctx, dbname, collname, spec, newdata)
+}
+
+// ModifyContext applies update operators (e.g. $set, $inc, $pull) to first
+// record matching given spec and returns number of matched records
+func ModifyContext(ctx context.Context, dbname, collname string, spec, update bson.M) (int, error) {
+	return Mongo.ModifyContext(ctx, dbname, collname, spec, update)
+}
+
+// CountContext returns number of records matching given spec
+func CountContext(ctx context.Context, dbname, collname string, spec bson.M) (int, error) {
+	return Mongo.CountContext(ctx, dbname, collname, spec)
+}
+
+// RemoveContext removes records matching given spec
+func RemoveContext(ctx context.Context, dbname, collname string, spec bson.M) error {
+	return Mongo.RemoveContext(ctx, dbname, collname, spec)
+}
+
+// CreateIndexContext creates index of given keys, e.g. unique index of
+// user logins, existing index with the same keys is kept
+func CreateIndexContext(ctx context.Context, dbname, collname string, unique bool, keys ...string) error {
+	return Mongo.CreateIndexContext(ctx, dbname, collname, unique, keys...)
+}
 
 // Insert records into MongoDB
 func Insert(dbname, collname string, records []Record) {
-	client := Mongo.Connect()
-	ctx := context.TODO()
-	c := client.Database(dbname).Collection(collname)
-	for _, rec := range records {
-		if _, err := c.InsertOne(ctx, &rec); err != nil {
-			log.Printf("Fail to insert record %v, error %v\n", rec, err)
-		}
-	}
+	Mongo.Insert(dbname, collname, records)
 }
 
 // Upsert records into MongoDB
 func Upsert(dbname, collname, attr string, records []Record) error {
-	client := Mongo.Connect()
-	ctx := context.TODO()
-	c := client.Database(dbname).Collection(collname)
-	for _, rec := range records {
-		value := rec[attr].(string)
-		if value == "" {
-			continue
-		}
-		spec := bson.M{attr: value}
-		update := bson.D{{"$set", rec}}
-		opts := options.Update().SetUpsert(true)
-		if _, err := c.UpdateOne(ctx, spec, update, opts); err != nil {
-			log.Printf("Fail to insert record %v, error %v\n", rec, err)
-			return err
-		}
-	}
-	return nil
+	return Mongo.Upsert(dbname, collname, attr, records)
 }
 
 // Get records from MongoDB
 func Get(dbname, collname string, spec bson.M, idx, limit int) []Record {
-	out := []Record{}
-	client := Mongo.Connect()
-	ctx := context.TODO()
-	c := client.Database(dbname).Collection(collname)
-	var err error
-	if limit > 0 {
-		opts := options.Find().SetSkip(int64(idx)).SetLimit(int64(limit))
-		cur, err := c.Find(ctx, spec, opts)
-		if err != nil {
-			log.Printf("ERROR: spec=%+v, error=%v", spec, err)
-		}
-		cur.All(ctx, &out)
-	} else {
-		opts := options.Find().SetSkip(int64(idx))
-		cur, err := c.Find(ctx, spec, opts)
-		if err != nil {
-			log.Printf("ERROR: spec=%+v, error=%v", spec, err)
-		}
-		cur.All(ctx, &out)
-	}
-	if err != nil {
-		log.Printf("Unable to get records, error %v\n", err)
-	}
-	return out
+	return Mongo.Get(dbname, collname, spec, idx, limit)
 }
 
 // GetSorted records from MongoDB sorted by given key
 func GetSorted(dbname, collname string, spec bson.M, skeys []string) []Record {
-	out := []Record{}
-	client := Mongo.Connect()
-	ctx := context.TODO()
-	c := client.Database(dbname).Collection(collname)
-	var sortSpec bson.M
-	for _, s := range skeys {
-		sortSpec[s] = 1
-	}
-	opts := options.Find().SetSort(sortSpec)
-	cur, err := c.Find(ctx, spec, opts)
-	cur.All(ctx, &out)
-	if err != nil {
-		log.Printf("Unable to sort records, error %v\n", err)
-		// try to fetch all unsorted data
-		cur, err := c.Find(ctx, spec)
-		if err != nil {
-			log.Printf("Unable to find records, error %v\n", err)
-			out = append(out, ErrorRecord(fmt.Sprintf("%v", err), DBErrorName, DBError))
-			return out
-		}
-		cur.All(ctx, &out)
-	}
-	return out
-}
-
-// helper function to present in bson selected fields
-func sel(q ...string) (r bson.M) {
-	r = make(bson.M, len(q))
-	for _, s := range q {
-		r[s] = 1
-	}
-	return
+	return Mongo.GetSorted(dbname, collname, spec, skeys)
 }
 
 // Update inplace for given spec
 func Update(dbname, collname string, spec, newdata bson.M) {
-	client := Mongo.Connect()
-	ctx := context.TODO()
-	c := client.Database(dbname).Collection(collname)
-	_, err := c.UpdateOne(ctx, spec, newdata)
-	if err != nil {
-		log.Printf("Unable to update record, spec %v, data %v, error %v\n", spec, newdata, err)
-	}
+	Mongo.Update(dbname, collname, spec, newdata)
 }
 
 // Count gets number records from MongoDB
 func Count(dbname, collname string, spec bson.M) int {
-	client := Mongo.Connect()
-	ctx := context.TODO()
-	c := client.Database(dbname).Collection(collname)
-	nrec, err := c.CountDocuments(ctx, spec)
-	if err != nil {
-		log.Printf("Unable to count records, spec %v, error %v\n", spec, err)
-	}
-	return int(nrec)
+	return Mongo.Count(dbname, collname, spec)
 }
 
 // Remove records from MongoDB
 func Remove(dbname, collname string, spec bson.M) {
-	client := Mongo.Connect()
-	ctx := context.TODO()
-	c := client.Database(dbname).Collection(collname)
-	_, err := c.DeleteMany(ctx, spec)
-	if err != nil {
-		log.Printf("Unable to remove records, spec %v, error %v\n", spec, err)
-	}
+	Mongo.Remove(dbname, collname, spec)
 }
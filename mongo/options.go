@@ -0,0 +1,92 @@
+package mongo
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// ConnectTimeout defines default timeout to establish MongoDB connection
+var ConnectTimeout = 10 * time.Second
+
+// DisconnectDelay defines how long client replaced by Connection.Reset
+// serves in-flight operations before it is disconnected
+var DisconnectDelay = 30 * time.Second
+
+// Options represents MongoDB connection options, zero values keep
+// driver defaults or values given in connection URI
+type Options struct {
+	AppName                string        // application name reported to MongoDB
+	MaxPoolSize            uint64        // maximum number of pooled connections
+	MinPoolSize            uint64        // minimum number of pooled connections
+	ConnectTimeout         time.Duration // timeout to establish connection
+	ServerSelectionTimeout time.Duration // timeout to select server for operation
+	SocketTimeout          time.Duration // timeout of socket reads and writes
+	ReadPreference         string        // primary, primaryPreferred, secondary, secondaryPreferred or nearest
+	WriteConcern           string        // majority or number of acknowledging nodes
+}
+
+// Validate checks connection options
+func (o Options) Validate() error {
+	opts := options.Client()
+	if err := o.apply(opts); err != nil {
+		return err
+	}
+	return opts.Validate()
+}
+
+// helper function to build driver client options
+func (o Options) clientOptions(uri string) (*options.ClientOptions, error) {
+	opts := options.Client().ApplyURI(uri)
+	if err := o.apply(opts); err != nil {
+		return nil, err
+	}
+	return opts, opts.Validate()
+}
+
+// helper function to apply connection options to driver client options
+func (o Options) apply(opts *options.ClientOptions) error {
+	if o.AppName != "" {
+		opts.SetAppName(o.AppName)
+	}
+	if o.MaxPoolSize > 0 {
+		opts.SetMaxPoolSize(o.MaxPoolSize)
+	}
+	if o.MinPoolSize > 0 {
+		opts.SetMinPoolSize(o.MinPoolSize)
+	}
+	if o.ConnectTimeout > 0 {
+		opts.SetConnectTimeout(o.ConnectTimeout)
+	}
+	if o.ServerSelectionTimeout > 0 {
+		opts.SetServerSelectionTimeout(o.ServerSelectionTimeout)
+	}
+	if o.SocketTimeout > 0 {
+		opts.SetSocketTimeout(o.SocketTimeout)
+	}
+	if o.ReadPreference != "" {
+		mode, err := readpref.ModeFromString(o.ReadPreference)
+		if err != nil {
+			return err
+		}
+		rp, err := readpref.New(mode)
+		if err != nil {
+			return err
+		}
+		opts.SetReadPreference(rp)
+	}
+	if o.WriteConcern != "" {
+		if o.WriteConcern == "majority" {
+			opts.SetWriteConcern(writeconcern.Majority())
+		} else if n, err := strconv.Atoi(o.WriteConcern); err == nil && n >= 0 {
+			opts.SetWriteConcern(&writeconcern.WriteConcern{W: n})
+		} else {
+			return fmt.Errorf("invalid write concern '%s'", o.WriteConcern)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// TestClientOptions
+func TestClientOptions(t *testing.T) {
+	opts := Options{
+		AppName:        "discovery",
+		MaxPoolSize:    20,
+		ConnectTimeout: 3 * time.Second,
+		ReadPreference: "secondaryPreferred",
+		WriteConcern:   "majority",
+	}
+	copts, err := opts.clientOptions("mongodb://localhost:8230")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *copts.AppName != "discovery" || *copts.MaxPoolSize != 20 || *copts.ConnectTimeout != 3*time.Second {
+		t.Errorf("wrong client options %+v", copts)
+	}
+	if copts.ReadPreference.Mode() != readpref.SecondaryPreferredMode || copts.WriteConcern.W != "majority" {
+		t.Errorf("wrong read preference or write concern %+v", copts)
+	}
+	for _, o := range []Options{{ReadPreference: "any"}, {WriteConcern: "all"}, {MinPoolSize: 10, MaxPoolSize: 5}} {
+		if _, err := o.clientOptions("mongodb://localhost:8230"); err == nil {
+			t.Errorf("invalid options %+v are accepted", o)
+		}
+	}
+}
+
+// TestPingClose
+func TestPingClose(t *testing.T) {
+	defer InitMongoDB(Mongo.URI, Mongo.Options)
+	InitMongoDB("mongodb://127.0.0.1:1", Options{ServerSelectionTimeout: 200 * time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Ping(ctx); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("unavailable database is not reported, error %v", err)
+	}
+	if Mongo.Client == nil {
+		t.Fatal("client is not connected")
+	}
+	if err := Close(ctx); err != nil || Mongo.Client != nil {
+		t.Errorf("client is not closed, error %v", err)
+	}
+	if err := Close(ctx); err != nil {
+		t.Errorf("second close fails with %v", err)
+	}
+	InitMongoDB("mongodb://127.0.0.1:1", Options{WriteConcern: "all"})
+	if err := Ping(ctx); err == nil || errors.Is(err, ErrUnavailable) {
+		t.Errorf("wrong error of invalid options %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package mongostore
+
+import (
+	"context"
+	"time"
+
+	auth "github.com/OreCast/common/authz"
+	"github.com/OreCast/common/mongo"
+	bson "go.mongodb.org/mongo-driver/bson"
+)
+
+// AuditTimeout defines default timeout of writing audit events, events are
+// emitted within authentication of requests which should not wait for slow
+// or unavailable database
+var AuditTimeout = time.Second
+
+// AuditSink stores audit events in MongoDB collection
+type AuditSink struct {
+	DBName  string            // database name
+	DBColl  string            // database collection
+	Conn    *mongo.Connection // MongoDB connection, mongo.Mongo if not set
+	Timeout time.Duration     // timeout of writing events, AuditTimeout if not set
+}
+
+// Emit implements auth.AuditSink interface
+func (s *AuditSink) Emit(event auth.AuditEvent) error {
+	rec := mongo.Record{
+		"timestamp": event.Timestamp,
+		"success":   event.Success,
+		"subject":   event.Subject,
+		"provider":  event.Provider,
+		"reason":    event.Reason,
+		"client_ip": event.ClientIP,
+		"route":     event.Route,
+		"method":    event.Method,
+	}
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = AuditTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return connection(s.Conn).InsertContext(ctx, s.DBName, s.DBColl, []mongo.Record{rec})
+}
+
+// Query implements auth.AuditSink interface
+func (s *AuditSink) Query(filter auth.AuditFilter) ([]auth.AuditEvent, error) {
+	var out []auth.AuditEvent
+	spec := bson.M{}
+	if filter.Subject != "" {
+		spec["subject"] = filter.Subject
+	}
+	if filter.ClientIP != "" {
+		spec["client_ip"] = filter.ClientIP
+	}
+	if filter.Failures {
+		spec["success"] = false
+	}
+	if !filter.Since.IsZero() {
+		spec["timestamp"] = bson.M{"$gte": filter.Since.Unix()}
+	}
+	// newest events first, limit is applied by MongoDB
+	skeys := []string{"-timestamp"}
+	records, err := connection(s.Conn).GetSortedRangeContext(context.TODO(), s.DBName, s.DBColl, spec, skeys, 0, filter.Limit)
+	if err != nil {
+		return out, err
+	}
+	for _, rec := range records {
+		event := auth.AuditEvent{}
+		event.Timestamp, _ = mongo.GetInt64Value(rec, "timestamp")
+		event.Success, _ = rec["success"].(bool)
+		event.Subject, _ = rec["subject"].(string)
+		event.Provider, _ = rec["provider"].(string)
+		event.Reason, _ = rec["reason"].(string)
+		event.ClientIP, _ = rec["client_ip"].(string)
+		event.Route, _ = rec["route"].(string)
+		event.Method, _ = rec["method"].(string)
+		out = append(out, event)
+	}
+	return out, nil
+}
@@ -0,0 +1,62 @@
+package mongostore
+
+import (
+	"context"
+	"errors"
+
+	auth "github.com/OreCast/common/authz"
+	"github.com/OreCast/common/mongo"
+	bson "go.mongodb.org/mongo-driver/bson"
+)
+
+// ClientStore stores OAuth clients in MongoDB collection
+type ClientStore struct {
+	DBName string            // database name
+	DBColl string            // database collection
+	Conn   *mongo.Connection // MongoDB connection, mongo.Mongo if not set
+}
+
+// GetClient implements auth.ClientStore interface
+func (s *ClientStore) GetClient(clientID string) (auth.Client, error) {
+	rec, err := connection(s.Conn).GetOneContext(context.TODO(), s.DBName, s.DBColl, bson.M{"client_id": clientID})
+	if errors.Is(err, mongo.ErrNotFound) {
+		return auth.Client{}, auth.ErrClientNotFound
+	} else if err != nil {
+		return auth.Client{}, err
+	}
+	client := auth.Client{}
+	client.ClientID, _ = rec["client_id"].(string)
+	client.Secret, _ = rec["secret"].(string)
+	client.Name, _ = rec["name"].(string)
+	client.GrantTypes = stringList(rec["grant_types"])
+	client.Scopes = stringList(rec["scopes"])
+	client.Created, _ = mongo.GetInt64Value(rec, "created")
+	return client, nil
+}
+
+// EnsureIndexes creates unique index of client ids
+func (s *ClientStore) EnsureIndexes(ctx context.Context) error {
+	return connection(s.Conn).CreateIndexContext(ctx, s.DBName, s.DBColl, true, "client_id")
+}
+
+// AddClient implements auth.ClientStore interface
+func (s *ClientStore) AddClient(client auth.Client) error {
+	rec := mongo.Record{
+		"client_id":   client.ClientID,
+		"secret":      client.Secret,
+		"name":        client.Name,
+		"grant_types": client.GrantTypes,
+		"scopes":      client.Scopes,
+		"created":     client.Created,
+	}
+	err := connection(s.Conn).InsertContext(context.TODO(), s.DBName, s.DBColl, []mongo.Record{rec})
+	if errors.Is(err, mongo.ErrDuplicate) {
+		return auth.ErrClientExists
+	}
+	return err
+}
+
+// RemoveClient implements auth.ClientStore interface
+func (s *ClientStore) RemoveClient(clientID string) error {
+	return connection(s.Conn).RemoveContext(context.TODO(), s.DBName, s.DBColl, bson.M{"client_id": clientID})
+}
@@ -0,0 +1,45 @@
+package mongostore
+
+import (
+	"context"
+
+	"github.com/OreCast/common/config"
+	"github.com/OreCast/common/mongo"
+	bson "go.mongodb.org/mongo-driver/bson"
+)
+
+// FeatureStore provides feature flags stored in MongoDB collection, every
+// record defines single feature, e.g.
+//
+//	{"name": "new_search", "enabled": false, "percentage": 20, "users": ["alice"]}
+type FeatureStore struct {
+	DBName string            // database name
+	DBColl string            // collection name
+	Conn   *mongo.Connection // MongoDB connection, mongo.Mongo if not set
+}
+
+// Features implements features.Store interface
+func (m *FeatureStore) Features() (map[string]config.Feature, error) {
+	records, err := connection(m.Conn).GetContext(context.TODO(), m.DBName, m.DBColl, bson.M{}, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]config.Feature)
+	for _, rec := range records {
+		name, _ := rec["name"].(string)
+		if name == "" {
+			continue
+		}
+		feature := config.Feature{
+			Users: stringList(rec["users"]),
+			Sites: stringList(rec["sites"]),
+		}
+		feature.Enabled, _ = rec["enabled"].(bool)
+		feature.Key, _ = rec["key"].(string)
+		if percentage, ok := numberValue(rec["percentage"]); ok {
+			feature.Percentage = int(percentage)
+		}
+		out[name] = feature
+	}
+	return out, nil
+}
@@ -0,0 +1,93 @@
+package mongostore
+
+// MongoDB backed stores of OreCast common modules
+//
+// The authz, config and features modules define storage interfaces and do
+// not depend on MongoDB, this module implements them on top of mongo
+// module, e.g.
+//
+//	conn := mongostore.NewConnection(cfg.Discovery.MongoDB)
+//	users := &mongostore.UserStore{DBName: "OreCast", DBColl: "users", Conn: conn}
+//	user, amr, err := auth.AuthenticateMFA(users, login, password, code)
+//
+// Stores use default mongo.Mongo connection if Conn is not set.
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/OreCast/common/config"
+	"github.com/OreCast/common/mongo"
+	bson "go.mongodb.org/mongo-driver/bson"
+)
+
+// Options returns MongoDB connection options of given configuration
+func Options(m config.MongoDB) mongo.Options {
+	return mongo.Options{
+		AppName:        m.AppName,
+		MaxPoolSize:    uint64(m.PoolSize),
+		ConnectTimeout: time.Duration(m.ConnectTimeout) * time.Second,
+		SocketTimeout:  time.Duration(m.Timeout) * time.Second,
+		ReadPreference: m.ReadPreference,
+		WriteConcern:   m.WriteConcern,
+	}
+}
+
+// NewConnection creates MongoDB connection of given configuration
+func NewConnection(m config.MongoDB) *mongo.Connection {
+	return mongo.NewConnection(m.DBUri, Options(m))
+}
+
+// Follow resets given connection (mongo.Mongo if nil) when MongoDB parameters
+// of given service section change on configuration reload, it returns
+// function to stop following
+func Follow(section string, conn *mongo.Connection) func() {
+	return config.Subscribe(section, func(old, new *config.OreCastConfig) {
+		m, ok := new.MongoDB(section)
+		if !ok {
+			return
+		}
+		if old != nil {
+			if prev, ok := old.MongoDB(section); ok && prev == m {
+				return
+			}
+		}
+		log.Printf("MongoDB parameters of %s are changed, reset connection", section)
+		ctx, cancel := context.WithTimeout(context.Background(), mongo.ConnectTimeout)
+		defer cancel()
+		if err := connection(conn).Reset(ctx, m.DBUri, Options(m)); err != nil {
+			log.Println("WARNING: unable to close MongoDB connection", err)
+		}
+	})
+}
+
+// helper function to return given connection or default one
+func connection(conn *mongo.Connection) *mongo.Connection {
+	if conn != nil {
+		return conn
+	}
+	return &mongo.Mongo
+}
+
+// helper function to convert list of values from mongo record into list of strings
+func stringList(val interface{}) []string {
+	var out []string
+	switch v := val.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+	case bson.A:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
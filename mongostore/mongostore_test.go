@@ -0,0 +1,49 @@
+package mongostore
+
+import (
+	"testing"
+	"time"
+
+	auth "github.com/OreCast/common/authz"
+	"github.com/OreCast/common/config"
+)
+
+// stores implement interfaces of authz and config modules
+var (
+	_ auth.UserStore      = (*UserStore)(nil)
+	_ auth.RoleStore      = (*RoleStore)(nil)
+	_ auth.ClientStore    = (*ClientStore)(nil)
+	_ auth.AuditSink      = (*AuditSink)(nil)
+	_ config.RemoteSource = (*RemoteSource)(nil)
+)
+
+// TestOptions
+func TestOptions(t *testing.T) {
+	m := config.MongoDB{DBUri: "mongodb://localhost:8230", AppName: "discovery", PoolSize: 10, ConnectTimeout: 3, WriteConcern: "majority"}
+	opts := Options(m)
+	if opts.AppName != "discovery" || opts.MaxPoolSize != 10 || opts.ConnectTimeout != 3*time.Second {
+		t.Errorf("wrong options %+v", opts)
+	}
+	if err := opts.Validate(); err != nil {
+		t.Error(err)
+	}
+	if conn := NewConnection(m); conn.URI != m.DBUri || conn.Options != opts {
+		t.Errorf("wrong connection %+v", conn)
+	}
+}
+
+// TestFollow
+func TestFollow(t *testing.T) {
+	m := config.MongoDB{DBUri: "mongodb://127.0.0.1:1"}
+	conn := NewConnection(m)
+	defer Follow(config.DiscoverySection, conn)()
+	cfg := &config.OreCastConfig{}
+	cfg.Discovery.MongoDB = m
+	config.Update(cfg)
+	cfg = &config.OreCastConfig{}
+	cfg.Discovery.MongoDB = config.MongoDB{DBUri: "mongodb://127.0.0.1:2", AppName: "discovery"}
+	config.Update(cfg)
+	if conn.URI != "mongodb://127.0.0.1:2" || conn.Options.AppName != "discovery" {
+		t.Errorf("connection is not reset %+v", conn)
+	}
+}
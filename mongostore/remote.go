@@ -0,0 +1,103 @@
+package mongostore
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/OreCast/common/config"
+	"github.com/OreCast/common/mongo"
+	bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RemoteSource provides remote configuration settings stored in MongoDB
+// collection, e.g.
+//
+//	loader := &config.Loader{File: cfile, Remote: &config.Remote{
+//		Source: &mongostore.RemoteSource{DBName: "OreCast", DBColl: "config", Name: "orecast"},
+//		Keys:   []string{"discovery.verbose", "frontend.rate"},
+//	}}
+type RemoteSource struct {
+	DBName string            // database name
+	DBColl string            // collection name
+	Name   string            // name of configuration documents
+	Conn   *mongo.Connection // MongoDB connection, mongo.Mongo if not set
+}
+
+// String returns description of the source
+func (m *RemoteSource) String() string {
+	return fmt.Sprintf("mongodb:%s.%s/%s", m.DBName, m.DBColl, m.Name)
+}
+
+// Fetch implements config.RemoteSource interface, it returns document with
+// highest version
+func (m *RemoteSource) Fetch(ctx context.Context) (config.RemoteSettings, error) {
+	var out config.RemoteSettings
+	spec := bson.M{"name": m.Name}
+	records, err := connection(m.Conn).GetSortedRangeContext(ctx, m.DBName, m.DBColl, spec, []string{"-version"}, 0, 1)
+	if err != nil {
+		return out, err
+	}
+	if len(records) == 0 {
+		return out, fmt.Errorf("no configuration documents %s", m.Name)
+	}
+	rec := records[0]
+	version, ok := numberValue(rec["version"])
+	if !ok {
+		return out, fmt.Errorf("invalid version %v of %s", rec["version"], m.Name)
+	}
+	settings, ok := documentMap(rec["settings"])
+	if !ok {
+		return out, fmt.Errorf("invalid settings of %s version %d", m.Name, version)
+	}
+	return config.RemoteSettings{Version: version, Settings: settings}, nil
+}
+
+// helper function to convert MongoDB number into int64, numbers are stored
+// as int32, int64 or double depending on the client
+func numberValue(val interface{}) (int64, bool) {
+	switch v := val.(type) {
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case float64:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+// helper function to convert MongoDB document into settings map
+func documentMap(val interface{}) (map[string]interface{}, bool) {
+	if doc, ok := val.(primitive.D); ok {
+		val = doc.Map()
+	}
+	rval := reflect.ValueOf(val)
+	if rval.Kind() != reflect.Map || rval.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+	out := make(map[string]interface{}, rval.Len())
+	iter := rval.MapRange()
+	for iter.Next() {
+		out[iter.Key().String()] = documentValue(iter.Value().Interface())
+	}
+	return out, true
+}
+
+// helper function to convert MongoDB value into settings value
+func documentValue(val interface{}) interface{} {
+	if m, ok := documentMap(val); ok {
+		return m
+	}
+	if arr, ok := val.(primitive.A); ok {
+		out := make([]interface{}, len(arr))
+		for i, item := range arr {
+			out[i] = documentValue(item)
+		}
+		return out
+	}
+	return val
+}
@@ -0,0 +1,39 @@
+package mongostore
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TestNumberValue
+func TestNumberValue(t *testing.T) {
+	for _, val := range []interface{}{int32(3), int64(3), 3, float64(3)} {
+		if v, ok := numberValue(val); !ok || v != 3 {
+			t.Errorf("wrong number value of %T", val)
+		}
+	}
+	if _, ok := numberValue("3"); ok {
+		t.Error("string is accepted as number")
+	}
+}
+
+// TestDocumentMap
+func TestDocumentMap(t *testing.T) {
+	doc := primitive.D{
+		{Key: "discovery", Value: primitive.M{"verbose": int32(1)}},
+		{Key: "frontend", Value: primitive.D{{Key: "domain_names", Value: primitive.A{"a", "b"}}}},
+	}
+	settings, ok := documentMap(doc)
+	if !ok {
+		t.Fatal("document is not converted")
+	}
+	discovery, _ := settings["discovery"].(map[string]interface{})
+	if discovery["verbose"] != int32(1) {
+		t.Errorf("wrong value %v", discovery["verbose"])
+	}
+	frontend, _ := settings["frontend"].(map[string]interface{})
+	if names, ok := frontend["domain_names"].([]interface{}); !ok || len(names) != 2 {
+		t.Errorf("wrong list value %v", frontend["domain_names"])
+	}
+}
@@ -0,0 +1,139 @@
+package mongostore
+
+import (
+	"context"
+	"errors"
+
+	auth "github.com/OreCast/common/authz"
+	"github.com/OreCast/common/mongo"
+	bson "go.mongodb.org/mongo-driver/bson"
+)
+
+// RoleStore stores groups and roles in MongoDB collections
+type RoleStore struct {
+	DBName    string            // database name
+	RoleColl  string            // roles collection
+	GroupColl string            // groups collection
+	Conn      *mongo.Connection // MongoDB connection, mongo.Mongo if not set
+}
+
+// EnsureIndexes creates unique indexes of role and group names
+func (s *RoleStore) EnsureIndexes(ctx context.Context) error {
+	if err := connection(s.Conn).CreateIndexContext(ctx, s.DBName, s.RoleColl, true, "name"); err != nil {
+		return err
+	}
+	return connection(s.Conn).CreateIndexContext(ctx, s.DBName, s.GroupColl, true, "name")
+}
+
+// GetRole implements auth.RoleStore interface
+func (s *RoleStore) GetRole(name string) (auth.Role, error) {
+	rec, err := connection(s.Conn).GetOneContext(context.TODO(), s.DBName, s.RoleColl, bson.M{"name": name})
+	if errors.Is(err, mongo.ErrNotFound) {
+		return auth.Role{}, auth.ErrRoleNotFound
+	} else if err != nil {
+		return auth.Role{}, err
+	}
+	return roleFromRecord(rec), nil
+}
+
+// Roles implements auth.RoleStore interface
+func (s *RoleStore) Roles() ([]auth.Role, error) {
+	var out []auth.Role
+	records, err := connection(s.Conn).GetContext(context.TODO(), s.DBName, s.RoleColl, bson.M{}, 0, 0)
+	for _, rec := range records {
+		out = append(out, roleFromRecord(rec))
+	}
+	return out, err
+}
+
+// SaveRole implements auth.RoleStore interface
+func (s *RoleStore) SaveRole(role auth.Role) error {
+	rec := mongo.Record{"name": role.Name, "permissions": role.Permissions}
+	return connection(s.Conn).UpsertContext(context.TODO(), s.DBName, s.RoleColl, "name", []mongo.Record{rec})
+}
+
+// RemoveRole implements auth.RoleStore interface
+func (s *RoleStore) RemoveRole(name string) error {
+	return connection(s.Conn).RemoveContext(context.TODO(), s.DBName, s.RoleColl, bson.M{"name": name})
+}
+
+// GetGroup implements auth.RoleStore interface
+func (s *RoleStore) GetGroup(name string) (auth.Group, error) {
+	rec, err := connection(s.Conn).GetOneContext(context.TODO(), s.DBName, s.GroupColl, bson.M{"name": name})
+	if errors.Is(err, mongo.ErrNotFound) {
+		return auth.Group{}, auth.ErrGroupNotFound
+	} else if err != nil {
+		return auth.Group{}, err
+	}
+	return groupFromRecord(rec), nil
+}
+
+// Groups implements auth.RoleStore interface
+func (s *RoleStore) Groups() ([]auth.Group, error) {
+	return s.groups(bson.M{})
+}
+
+// SaveGroup implements auth.RoleStore interface
+func (s *RoleStore) SaveGroup(group auth.Group) error {
+	rec := mongo.Record{"name": group.Name, "members": group.Members, "roles": group.Roles}
+	return connection(s.Conn).UpsertContext(context.TODO(), s.DBName, s.GroupColl, "name", []mongo.Record{rec})
+}
+
+// RemoveGroup implements auth.RoleStore interface
+func (s *RoleStore) RemoveGroup(name string) error {
+	return connection(s.Conn).RemoveContext(context.TODO(), s.DBName, s.GroupColl, bson.M{"name": name})
+}
+
+// AddMember implements auth.RoleStore interface
+func (s *RoleStore) AddMember(group, login string) error {
+	return s.member(group, bson.M{"$addToSet": bson.M{"members": login}})
+}
+
+// RemoveMember implements auth.RoleStore interface
+func (s *RoleStore) RemoveMember(group, login string) error {
+	return s.member(group, bson.M{"$pull": bson.M{"members": login}})
+}
+
+// helper function to update members of given group
+func (s *RoleStore) member(group string, update bson.M) error {
+	nrec, err := connection(s.Conn).ModifyContext(context.TODO(), s.DBName, s.GroupColl, bson.M{"name": group}, update)
+	if err != nil {
+		return err
+	}
+	if nrec == 0 {
+		return auth.ErrGroupNotFound
+	}
+	return nil
+}
+
+// UserGroups implements auth.RoleStore interface
+func (s *RoleStore) UserGroups(login string) ([]auth.Group, error) {
+	return s.groups(bson.M{"members": login})
+}
+
+// helper function to get groups matching given spec
+func (s *RoleStore) groups(spec bson.M) ([]auth.Group, error) {
+	var out []auth.Group
+	records, err := connection(s.Conn).GetContext(context.TODO(), s.DBName, s.GroupColl, spec, 0, 0)
+	for _, rec := range records {
+		out = append(out, groupFromRecord(rec))
+	}
+	return out, err
+}
+
+// helper function to convert mongo record into role
+func roleFromRecord(rec mongo.Record) auth.Role {
+	role := auth.Role{}
+	role.Name, _ = rec["name"].(string)
+	role.Permissions = stringList(rec["permissions"])
+	return role
+}
+
+// helper function to convert mongo record into group
+func groupFromRecord(rec mongo.Record) auth.Group {
+	group := auth.Group{}
+	group.Name, _ = rec["name"].(string)
+	group.Members = stringList(rec["members"])
+	group.Roles = stringList(rec["roles"])
+	return group
+}
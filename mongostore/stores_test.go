@@ -0,0 +1,101 @@
+package mongostore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	auth "github.com/OreCast/common/authz"
+	"github.com/OreCast/common/mongo"
+)
+
+// helper function to create connection to unavailable database
+func unavailable(t *testing.T) *mongo.Connection {
+	conn := mongo.NewConnection("mongodb://127.0.0.1:1", mongo.Options{ServerSelectionTimeout: 200 * time.Millisecond})
+	t.Cleanup(func() { conn.Close(context.Background()) })
+	return conn
+}
+
+// TestStoreWriteErrors
+func TestStoreWriteErrors(t *testing.T) {
+	conn := unavailable(t)
+	users := &UserStore{DBName: "db", DBColl: "users", Conn: conn}
+	clients := &ClientStore{DBName: "db", DBColl: "clients", Conn: conn}
+	roles := &RoleStore{DBName: "db", RoleColl: "roles", GroupColl: "groups", Conn: conn}
+	audit := &AuditSink{DBName: "db", DBColl: "audit", Conn: conn}
+	for name, err := range map[string]error{
+		"Emit":          audit.Emit(auth.AuditEvent{Subject: "alice"}),
+		"AddUser":       users.AddUser(auth.User{Login: "alice"}),
+		"UpdateUser":    users.UpdateUser(auth.User{Login: "alice"}),
+		"RecordFailure": users.RecordFailure("alice", 5, 0),
+		"ResetFailures": users.ResetFailures("alice"),
+		"UpdateTOTP":    users.UpdateTOTP(auth.User{Login: "alice"}),
+		"AddClient":     clients.AddClient(auth.Client{ClientID: "id"}),
+		"RemoveClient":  clients.RemoveClient("id"),
+		"RemoveRole":    roles.RemoveRole("admin"),
+		"RemoveGroup":   roles.RemoveGroup("admins"),
+	} {
+		if !errors.Is(err, mongo.ErrUnavailable) {
+			t.Errorf("%s does not report unavailable database, error %v", name, err)
+		}
+	}
+}
+
+// TestStoreReadErrors
+func TestStoreReadErrors(t *testing.T) {
+	conn := unavailable(t)
+	users := &UserStore{DBName: "db", DBColl: "users", Conn: conn}
+	clients := &ClientStore{DBName: "db", DBColl: "clients", Conn: conn}
+	roles := &RoleStore{DBName: "db", RoleColl: "roles", GroupColl: "groups", Conn: conn}
+	_, userErr := users.GetUser("alice")
+	_, clientErr := clients.GetClient("id")
+	_, roleErr := roles.GetRole("admin")
+	_, rolesErr := roles.Roles()
+	_, groupErr := roles.GetGroup("admins")
+	_, groupsErr := roles.Groups()
+	_, userGroupsErr := roles.UserGroups("alice")
+	audit := &AuditSink{DBName: "db", DBColl: "audit", Conn: conn}
+	_, auditErr := audit.Query(auth.AuditFilter{Limit: 10})
+	features := &FeatureStore{DBName: "db", DBColl: "features", Conn: conn}
+	_, featuresErr := features.Features()
+	remote := &RemoteSource{DBName: "db", DBColl: "config", Name: "orecast", Conn: conn}
+	_, remoteErr := remote.Fetch(context.Background())
+	for name, err := range map[string]error{
+		"GetUser":    userErr,
+		"GetClient":  clientErr,
+		"GetRole":    roleErr,
+		"Roles":      rolesErr,
+		"GetGroup":   groupErr,
+		"Groups":     groupsErr,
+		"UserGroups": userGroupsErr,
+		"Query":      auditErr,
+		"Features":   featuresErr,
+		"Fetch":      remoteErr,
+	} {
+		if !errors.Is(err, mongo.ErrUnavailable) {
+			t.Errorf("%s does not report unavailable database, error %v", name, err)
+		}
+	}
+	// database outage is not reported as wrong credentials
+	if _, err := auth.Authenticate(users, "alice", "secret"); !errors.Is(err, mongo.ErrUnavailable) {
+		t.Errorf("Authenticate hides unavailable database, error %v", err)
+	}
+	if _, _, err := auth.RegisterUser(users, "alice", "", "secret"); !errors.Is(err, mongo.ErrUnavailable) {
+		t.Errorf("RegisterUser hides unavailable database, error %v", err)
+	}
+}
+
+// TestAuditTimeout
+func TestAuditTimeout(t *testing.T) {
+	conn := mongo.NewConnection("mongodb://127.0.0.1:1", mongo.Options{ServerSelectionTimeout: 10 * time.Second})
+	defer conn.Close(context.Background())
+	audit := &AuditSink{DBName: "db", DBColl: "audit", Conn: conn, Timeout: 100 * time.Millisecond}
+	start := time.Now()
+	if err := audit.Emit(auth.AuditEvent{Subject: "alice"}); err == nil {
+		t.Fatal("event is written to unavailable database")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("audit event waits for unavailable database %v", elapsed)
+	}
+}
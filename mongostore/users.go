@@ -0,0 +1,178 @@
+package mongostore
+
+import (
+	"context"
+	"errors"
+
+	auth "github.com/OreCast/common/authz"
+	"github.com/OreCast/common/mongo"
+	bson "go.mongodb.org/mongo-driver/bson"
+)
+
+// UserStore stores user accounts in MongoDB collection
+type UserStore struct {
+	DBName string            // database name
+	DBColl string            // database collection
+	Conn   *mongo.Connection // MongoDB connection, mongo.Mongo if not set
+}
+
+// GetUser implements auth.UserStore interface
+func (s *UserStore) GetUser(login string) (auth.User, error) {
+	rec, err := connection(s.Conn).GetOneContext(context.TODO(), s.DBName, s.DBColl, bson.M{"login": login})
+	if errors.Is(err, mongo.ErrNotFound) {
+		return auth.User{}, auth.ErrUserNotFound
+	} else if err != nil {
+		return auth.User{}, err
+	}
+	user := auth.User{}
+	user.Login, _ = rec["login"].(string)
+	user.Email, _ = rec["email"].(string)
+	user.Password, _ = rec["password"].(string)
+	user.Verified, _ = rec["verified"].(bool)
+	user.VerifyToken, _ = rec["verify_token"].(string)
+	user.ResetToken, _ = rec["reset_token"].(string)
+	user.ResetExpires, _ = mongo.GetInt64Value(rec, "reset_expires")
+	user.LockedUntil, _ = mongo.GetInt64Value(rec, "locked_until")
+	user.Created, _ = mongo.GetInt64Value(rec, "created")
+	user.PassChanged, _ = mongo.GetInt64Value(rec, "pass_changed")
+	uid, _ := mongo.GetInt64Value(rec, "uid")
+	user.Uid = int(uid)
+	failures, _ := mongo.GetInt64Value(rec, "failures")
+	user.Failures = int(failures)
+	user.TOTPSecret, _ = rec["totp_secret"].(string)
+	user.TOTPEnabled, _ = rec["totp_enabled"].(bool)
+	user.TOTPLastStep, _ = mongo.GetInt64Value(rec, "totp_last_step")
+	user.RecoveryCodes = stringList(rec["recovery_codes"])
+	return user, nil
+}
+
+// EnsureIndexes creates unique indexes of user logins and ids
+func (s *UserStore) EnsureIndexes(ctx context.Context) error {
+	if err := connection(s.Conn).CreateIndexContext(ctx, s.DBName, s.DBColl, true, "login"); err != nil {
+		return err
+	}
+	return connection(s.Conn).CreateIndexContext(ctx, s.DBName, s.DBColl, true, "uid")
+}
+
+// AddUser implements auth.UserStore interface, concurrent registrations of
+// the same login and duplicate user ids are rejected by unique indexes, see
+// EnsureIndexes
+func (s *UserStore) AddUser(user auth.User) error {
+	if _, err := s.GetUser(user.Login); err == nil {
+		return auth.ErrUserExists
+	} else if !errors.Is(err, auth.ErrUserNotFound) {
+		return err
+	}
+	err := connection(s.Conn).InsertContext(context.TODO(), s.DBName, s.DBColl, []mongo.Record{userRecord(user)})
+	if errors.Is(err, mongo.ErrDuplicate) {
+		if _, err := s.GetUser(user.Login); err == nil {
+			return auth.ErrUserExists
+		}
+		return auth.ErrUserIDExists
+	}
+	return err
+}
+
+// UpdateUser implements auth.UserStore interface, it sets user fields
+// except failed logins, lockout and TOTP fields which have own updates
+func (s *UserStore) UpdateUser(user auth.User) error {
+	rec := userRecord(user)
+	for _, key := range []string{"failures", "locked_until", "totp_secret", "totp_enabled", "totp_last_step", "recovery_codes"} {
+		delete(rec, key)
+	}
+	return s.set(user.Login, rec)
+}
+
+// UpdateTOTP implements auth.UserStore interface
+func (s *UserStore) UpdateTOTP(user auth.User) error {
+	rec := mongo.Record{
+		"totp_secret":    user.TOTPSecret,
+		"totp_enabled":   user.TOTPEnabled,
+		"recovery_codes": user.RecoveryCodes,
+	}
+	return s.set(user.Login, rec)
+}
+
+// helper function to set fields of existing user
+func (s *UserStore) set(login string, rec mongo.Record) error {
+	spec := bson.M{"login": login}
+	nrec, err := connection(s.Conn).ModifyContext(context.TODO(), s.DBName, s.DBColl, spec, bson.M{"$set": rec})
+	if err != nil {
+		return err
+	}
+	if nrec == 0 {
+		return auth.ErrUserNotFound
+	}
+	return nil
+}
+
+// UseTOTPStep implements auth.UserStore interface, the step is stored by
+// conditional update which matches only smaller last used steps
+func (s *UserStore) UseTOTPStep(login string, step int64) (bool, error) {
+	spec := bson.M{"login": login, "totp_last_step": bson.M{"$lt": step}}
+	update := bson.M{"$set": bson.M{"totp_last_step": step}}
+	nrec, err := connection(s.Conn).ModifyContext(context.TODO(), s.DBName, s.DBColl, spec, update)
+	return nrec > 0, err
+}
+
+// RemoveRecoveryCode implements auth.UserStore interface, only one of
+// concurrent removals of the same code matches the record
+func (s *UserStore) RemoveRecoveryCode(login, hash string) (bool, error) {
+	spec := bson.M{"login": login, "recovery_codes": hash}
+	update := bson.M{"$pull": bson.M{"recovery_codes": hash}}
+	nrec, err := connection(s.Conn).ModifyContext(context.TODO(), s.DBName, s.DBColl, spec, update)
+	return nrec > 0, err
+}
+
+// RecordFailure implements auth.UserStore interface, failed logins are
+// incremented atomically and the account is locked by conditional update
+// once they reach max
+func (s *UserStore) RecordFailure(login string, max int, lockUntil int64) error {
+	conn := connection(s.Conn)
+	spec := bson.M{"login": login}
+	update := bson.M{"$inc": bson.M{"failures": int64(1)}}
+	nrec, err := conn.ModifyContext(context.TODO(), s.DBName, s.DBColl, spec, update)
+	if err != nil {
+		return err
+	}
+	if nrec == 0 {
+		return auth.ErrUserNotFound
+	}
+	if max <= 0 {
+		return nil
+	}
+	spec = bson.M{"login": login, "failures": bson.M{"$gte": int64(max)}}
+	update = bson.M{"$set": bson.M{"failures": int64(0), "locked_until": lockUntil}}
+	_, err = conn.ModifyContext(context.TODO(), s.DBName, s.DBColl, spec, update)
+	return err
+}
+
+// ResetFailures implements auth.UserStore interface
+func (s *UserStore) ResetFailures(login string) error {
+	spec := bson.M{"login": login}
+	update := bson.M{"$set": bson.M{"failures": int64(0), "locked_until": int64(0)}}
+	_, err := connection(s.Conn).ModifyContext(context.TODO(), s.DBName, s.DBColl, spec, update)
+	return err
+}
+
+// helper function to convert user into mongo record
+func userRecord(user auth.User) mongo.Record {
+	return mongo.Record{
+		"uid":            int64(user.Uid),
+		"login":          user.Login,
+		"email":          user.Email,
+		"password":       user.Password,
+		"verified":       user.Verified,
+		"verify_token":   user.VerifyToken,
+		"reset_token":    user.ResetToken,
+		"reset_expires":  user.ResetExpires,
+		"failures":       int64(user.Failures),
+		"locked_until":   user.LockedUntil,
+		"created":        user.Created,
+		"pass_changed":   user.PassChanged,
+		"totp_secret":    user.TOTPSecret,
+		"totp_enabled":   user.TOTPEnabled,
+		"totp_last_step": user.TOTPLastStep,
+		"recovery_codes": user.RecoveryCodes,
+	}
+}
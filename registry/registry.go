@@ -0,0 +1,290 @@
+package registry
+
+// registry of OreCast services
+//
+// Registry resolves service names to URLs of Services configuration, every
+// URL may hold comma separated list of service replicas, e.g.
+//
+//	services:
+//	  discovery_url: http://host1:8320,http://host2:8320
+//
+// Replicas are periodically probed under base path of their service section,
+// e.g. http://host1:8320/discovery/healthz, and URL returns healthy replicas
+// in round-robin order, e.g.
+//
+//	reg := registry.New(config.Current())
+//	stop := reg.Start()
+//	defer stop()
+//	rurl, err := reg.URL(config.DiscoverySection)
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OreCast/common/config"
+)
+
+// ErrUnknownService is returned for services which are not registered
+var ErrUnknownService = errors.New("unknown service")
+
+// ErrNoHealthyService is returned when all replicas of the service are down
+var ErrNoHealthyService = errors.New("no healthy service replicas")
+
+// Check defines health check of service URL, it returns error if service is down
+type Check func(ctx context.Context, client *http.Client, rurl string) error
+
+// HTTPCheck returns health check which performs GET request of given path of
+// service URL, service is considered down on connection errors and non 2XX codes
+func HTTPCheck(path string) Check {
+	return func(ctx context.Context, client *http.Client, rurl string) error {
+		req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimRight(rurl, "/")+path, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+			return fmt.Errorf("health check status %s", resp.Status)
+		}
+		return nil
+	}
+}
+
+// Endpoint represents status of service replica
+type Endpoint struct {
+	URL      string        `json:"url"`             // URL of the replica
+	Base     string        `json:"base,omitempty"`  // base path of service end-points
+	Up       bool          `json:"up"`              // replica is healthy
+	Error    string        `json:"error,omitempty"` // error of last health check
+	Checked  time.Time     `json:"checked"`         // time of last health check
+	Latency  time.Duration `json:"latency"`         // duration of last health check
+	Failures int           `json:"failures"`        // number of consecutive failed checks
+}
+
+// Registry keeps track of OreCast services and their health
+type Registry struct {
+	Interval time.Duration // interval of health checks
+	Timeout  time.Duration // timeout of single health check
+	Check    Check         // health check of service replicas
+	Client   *http.Client  // HTTP client used by health checks
+
+	mutex    sync.RWMutex
+	services map[string][]*Endpoint
+	next     map[string]int
+}
+
+// New creates registry of services defined in Services section of given
+// configuration, service names are configuration sections, e.g. discovery,
+// and replicas are checked under base path of their section
+func New(cfg *config.OreCastConfig) *Registry {
+	r := &Registry{
+		Interval: 30 * time.Second,
+		Timeout:  5 * time.Second,
+		Check:    HTTPCheck("/healthz"),
+		Client:   &http.Client{},
+	}
+	urls := map[string]string{
+		config.FrontendSection:        cfg.Services.FrontendURL,
+		config.DiscoverySection:       cfg.Services.DiscoveryURL,
+		config.MetaDataSection:        cfg.Services.MetaDataURL,
+		config.DataManagementSection:  cfg.Services.DataManagementURL,
+		config.DataBookkeepingSection: cfg.Services.DataBookkeepingURL,
+		config.AuthzSection:           cfg.Services.AuthzURL,
+	}
+	for name, rurl := range urls {
+		if rurl != "" {
+			r.Add(name, strings.Split(rurl, ",")...)
+			if ws, ok := cfg.WebServer(name); ok {
+				r.SetBase(name, ws.Base)
+			}
+		}
+	}
+	return r
+}
+
+// Add registers replicas of given service, replicas are considered healthy
+// until their first health check
+func (r *Registry) Add(name string, urls ...string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.services == nil {
+		r.services = make(map[string][]*Endpoint)
+		r.next = make(map[string]int)
+	}
+	for _, rurl := range urls {
+		rurl = strings.TrimSpace(rurl)
+		if rurl == "" {
+			continue
+		}
+		r.services[name] = append(r.services[name], &Endpoint{URL: rurl, Up: true})
+	}
+}
+
+// SetBase sets base path of end-points of given service, health checks of its
+// replicas are performed under it
+func (r *Registry) SetBase(name, base string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	base = strings.Trim(base, "/")
+	if base != "" {
+		base = "/" + base
+	}
+	for _, e := range r.services[name] {
+		e.Base = base
+	}
+}
+
+// Names returns sorted names of registered services
+func (r *Registry) Names() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	var out []string
+	for name := range r.services {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Status returns status of all replicas of given service
+func (r *Registry) Status(name string) ([]Endpoint, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	endpoints, ok := r.services[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownService, name)
+	}
+	var out []Endpoint
+	for _, e := range endpoints {
+		out = append(out, *e)
+	}
+	return out, nil
+}
+
+// StatusHandler provides status of all services in JSON format
+func (r *Registry) StatusHandler(w http.ResponseWriter, req *http.Request) {
+	out := make(map[string][]Endpoint)
+	for _, name := range r.Names() {
+		out[name], _ = r.Status(name)
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// Up reports if given service has at least one healthy replica
+func (r *Registry) Up(name string) bool {
+	_, err := r.URL(name)
+	return err == nil
+}
+
+// URL returns URL of healthy replica of given service, replicas are
+// selected in round-robin order
+func (r *Registry) URL(name string) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	endpoints, ok := r.services[name]
+	if !ok {
+		return "", fmt.Errorf("%w %s", ErrUnknownService, name)
+	}
+	for i := 0; i < len(endpoints); i++ {
+		idx := (r.next[name] + i) % len(endpoints)
+		if endpoints[idx].Up {
+			r.next[name] = idx + 1
+			return endpoints[idx].URL, nil
+		}
+	}
+	return "", fmt.Errorf("%w of %s", ErrNoHealthyService, name)
+}
+
+// Probe performs health checks of all replicas concurrently
+func (r *Registry) Probe(ctx context.Context) {
+	r.mutex.RLock()
+	var endpoints []*Endpoint
+	for _, list := range r.services {
+		endpoints = append(endpoints, list...)
+	}
+	r.mutex.RUnlock()
+	var wg sync.WaitGroup
+	for _, e := range endpoints {
+		wg.Add(1)
+		go func(e *Endpoint) {
+			defer wg.Done()
+			r.probe(ctx, e)
+		}(e)
+	}
+	wg.Wait()
+}
+
+// helper function to check health of single replica
+func (r *Registry) probe(ctx context.Context, e *Endpoint) {
+	cctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+	start := time.Now()
+	r.mutex.RLock()
+	rurl := strings.TrimRight(e.URL, "/") + e.Base
+	r.mutex.RUnlock()
+	err := r.Check(cctx, r.Client, rurl)
+	if ctx.Err() != nil {
+		// probes are stopped, keep last status
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	e.Checked = start
+	e.Latency = time.Since(start)
+	if err != nil {
+		if e.Up {
+			log.Printf("WARNING: service %s is down, error %v", e.URL, err)
+		}
+		e.Up = false
+		e.Error = err.Error()
+		e.Failures++
+		return
+	}
+	if !e.Up {
+		log.Printf("service %s is up", e.URL)
+	}
+	e.Up = true
+	e.Error = ""
+	e.Failures = 0
+}
+
+// Start probes services immediately and then periodically with registry
+// interval, it returns function which stops health checks
+func (r *Registry) Start() func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(r.Interval)
+		defer ticker.Stop()
+		for {
+			r.Probe(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,129 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/OreCast/common/config"
+)
+
+// TestRegistry
+func TestRegistry(t *testing.T) {
+	var healthy atomic.Bool
+	healthy.Store(true)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/discovery/healthz" {
+			w.WriteHeader(http.StatusNotFound)
+		} else if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}
+	srv1 := httptest.NewServer(http.HandlerFunc(handler))
+	defer srv1.Close()
+	srv2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv2.Close()
+
+	cfg := &config.OreCastConfig{}
+	cfg.Discovery.Base = "/discovery"
+	cfg.Services = config.Services{DiscoveryURL: srv1.URL + ", " + srv2.URL, AuthzURL: "http://127.0.0.1:1"}
+	reg := New(cfg)
+	if names := reg.Names(); len(names) != 2 || names[0] != "authz" || names[1] != "discovery" {
+		t.Errorf("wrong services %v", names)
+	}
+	reg.Probe(context.Background())
+	seen := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		rurl, err := reg.URL(config.DiscoverySection)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[rurl]++
+	}
+	if seen[srv1.URL] != 2 || seen[srv2.URL] != 2 {
+		t.Errorf("replicas are not balanced %v", seen)
+	}
+	if _, err := reg.URL(config.AuthzSection); !errors.Is(err, ErrNoHealthyService) || reg.Up(config.AuthzSection) {
+		t.Errorf("unreachable service is not reported, error %v", err)
+	}
+	if _, err := reg.URL("bla"); !errors.Is(err, ErrUnknownService) {
+		t.Errorf("unknown service is not reported, error %v", err)
+	}
+
+	// unhealthy replica is skipped
+	healthy.Store(false)
+	reg.Probe(context.Background())
+	for i := 0; i < 2; i++ {
+		if rurl, _ := reg.URL(config.DiscoverySection); rurl != srv2.URL {
+			t.Errorf("unhealthy replica %s is used", rurl)
+		}
+	}
+	status, err := reg.Status(config.DiscoverySection)
+	if err != nil || status[0].Up || status[0].Failures != 1 || !status[1].Up {
+		t.Errorf("wrong status %+v, error %v", status, err)
+	}
+
+	rec := httptest.NewRecorder()
+	reg.StatusHandler(rec, httptest.NewRequest("GET", "/services", nil))
+	var out map[string][]Endpoint
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out["discovery"]) != 2 || out["authz"][0].Up {
+		t.Errorf("wrong status response %+v", out)
+	}
+
+	// periodic checks
+	healthy.Store(true)
+	reg.Interval = 10 * time.Millisecond
+	stop := reg.Start()
+	defer stop()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, _ := reg.Status(config.DiscoverySection)
+		if status[0].Up {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("recovered replica is not reported")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestHTTPCheck
+func TestHTTPCheck(t *testing.T) {
+	var code atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/base/healthz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(int(code.Load()))
+	}))
+	defer srv.Close()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	check := HTTPCheck("/healthz")
+	for _, c := range []int{http.StatusOK, http.StatusNoContent} {
+		code.Store(int32(c))
+		if err := check(context.Background(), client, srv.URL+"/base/"); err != nil {
+			t.Errorf("status %d is not healthy, error %v", c, err)
+		}
+	}
+	for _, c := range []int{http.StatusFound, http.StatusNotFound, http.StatusServiceUnavailable} {
+		code.Store(int32(c))
+		if err := check(context.Background(), client, srv.URL+"/base"); err == nil {
+			t.Errorf("status %d is healthy", c)
+		}
+	}
+	if err := check(context.Background(), client, srv.URL); err == nil {
+		t.Error("missing health end-point is healthy")
+	}
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OreCast/common/config"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// HeadersMiddleware sets standard headers of server responses
+func HeadersMiddleware(cfg config.WebServer) gin.HandlerFunc {
+	opts := cfg.XContentTypeOptions
+	if opts == "" {
+		opts = "nosniff"
+	}
+	return func(c *gin.Context) {
+		c.Header("X-Content-Type-Options", opts)
+		c.Next()
+	}
+}
+
+// ParseRate parses rate limiter value in <limit>-<period> form, where period
+// is S, M, H or D, e.g. 100-S stands for 100 requests per second
+func ParseRate(value string) (rate.Limit, int, error) {
+	parts := strings.Split(value, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid rate %q, expect <limit>-<S|M|H|D>", value)
+	}
+	limit, err := strconv.Atoi(parts[0])
+	if err != nil || limit <= 0 {
+		return 0, 0, fmt.Errorf("invalid rate limit %q", parts[0])
+	}
+	periods := map[string]time.Duration{
+		"S": time.Second,
+		"M": time.Minute,
+		"H": time.Hour,
+		"D": 24 * time.Hour,
+	}
+	period, ok := periods[strings.ToUpper(parts[1])]
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid rate period %q", parts[1])
+	}
+	return rate.Limit(float64(limit) / period.Seconds()), limit, nil
+}
+
+// LimiterIdle defines minimal time after which limiters of idle clients are
+// evicted, limiters are kept at least until they are fully replenished
+var LimiterIdle = 10 * time.Minute
+
+// ipLimiter represents rate limiter of client IP
+type ipLimiter struct {
+	limiter *rate.Limiter
+	seen    time.Time // time of the last request
+}
+
+// ipLimiters holds rate limiters of client IPs and evicts idle ones
+type ipLimiters struct {
+	limit    rate.Limit
+	burst    int
+	idle     time.Duration // idle period after which limiter is evicted
+	mutex    sync.Mutex
+	limiters map[string]*ipLimiter
+	swept    time.Time // time of the last eviction
+}
+
+// helper function to create limiters with given rate
+func newIPLimiters(limit rate.Limit, burst int) *ipLimiters {
+	// limiter idle for the time it needs to refill its bucket is
+	// equivalent to the new one
+	idle := time.Duration(float64(burst) / float64(limit) * float64(time.Second))
+	if idle < LimiterIdle {
+		idle = LimiterIdle
+	}
+	return &ipLimiters{
+		limit:    limit,
+		burst:    burst,
+		idle:     idle,
+		limiters: make(map[string]*ipLimiter),
+		swept:    time.Now(),
+	}
+}
+
+// get returns limiter of given IP and evicts idle limiters
+func (l *ipLimiters) get(ip string, now time.Time) *rate.Limiter {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if now.Sub(l.swept) > l.idle {
+		for key, entry := range l.limiters {
+			if now.Sub(entry.seen) > l.idle {
+				delete(l.limiters, key)
+			}
+		}
+		l.swept = now
+	}
+	entry, ok := l.limiters[ip]
+	if !ok {
+		entry = &ipLimiter{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[ip] = entry
+	}
+	entry.seen = now
+	return entry.limiter
+}
+
+// LimiterMiddleware limits rate of requests per client IP, empty value
+// disables the limiter
+func LimiterMiddleware(value string) (gin.HandlerFunc, error) {
+	if value == "" {
+		return func(c *gin.Context) { c.Next() }, nil
+	}
+	limit, burst, err := ParseRate(value)
+	if err != nil {
+		return nil, err
+	}
+	limiters := newIPLimiters(limit, burst)
+	return func(c *gin.Context) {
+		limiter := limiters.get(c.ClientIP(), time.Now())
+		if !limiter.Allow() {
+			c.AbortWithStatusJSON(
+				http.StatusTooManyRequests, gin.H{"status": "fail", "error": "too many requests"})
+			return
+		}
+		c.Next()
+	}, nil
+}
@@ -0,0 +1,167 @@
+package server
+
+// HTTP server of OreCast services
+//
+// Services build their server from WebServer configuration, add their routes
+// to the router group of base path and run it until SIGTERM, e.g.
+//
+//	srv, err := server.New(config.Current().Discovery.WebServer)
+//	srv.Group.GET("/sites", SitesHandler)
+//	err = srv.Run()
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"syscall"
+	"time"
+
+	"github.com/OreCast/common/config"
+	"github.com/gin-gonic/gin"
+)
+
+// DrainTimeout defines default time given to in-flight requests on shutdown
+var DrainTimeout = 30 * time.Second
+
+// Server represents HTTP server of OreCast service
+type Server struct {
+	Config       config.WebServer // web server configuration
+	Router       *gin.Engine      // router of the server
+	Group        *gin.RouterGroup // router group of base path for service routes
+	TLSConfig    *tls.Config      // TLS configuration, see TLSConfig function
+	DrainTimeout time.Duration    // time given to in-flight requests on shutdown
+
+	logWriter io.WriteCloser // server log file
+}
+
+// New builds server from given web server configuration: router with
+// standard middleware, base path group, static files and health end-points.
+// Gin mode is global and it is left to services, e.g. gin.SetMode(gin.ReleaseMode)
+func New(cfg config.WebServer) (*Server, error) {
+	srv := &Server{Config: cfg, DrainTimeout: DrainTimeout}
+	var logWriter io.Writer = os.Stdout
+	if cfg.LogFile != "" {
+		file, err := os.OpenFile(cfg.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open log file %s, error %v", cfg.LogFile, err)
+		}
+		srv.logWriter = file
+		logWriter = file
+	}
+	limiter, err := LimiterMiddleware(cfg.LimiterPeriod)
+	if err != nil {
+		srv.Close()
+		return nil, err
+	}
+	// client IP of rate limiter and logs is taken from X-Forwarded-For
+	// headers only of trusted proxies
+	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		srv.Close()
+		return nil, fmt.Errorf("invalid trusted proxies %v, error %v", cfg.TrustedProxies, err)
+	}
+	router.Use(
+		gin.LoggerWithWriter(logWriter),
+		gin.RecoveryWithWriter(logWriter),
+		HeadersMiddleware(cfg),
+		limiter,
+	)
+	base := path.Join("/", cfg.Base)
+	srv.Router = router
+	srv.Group = router.Group(base)
+	if cfg.StaticDir != "" {
+		srv.Group.Static("/static", cfg.StaticDir)
+	}
+	srv.Group.GET("/healthz", HealthHandler)
+	srv.Group.GET("/version", gin.WrapF(config.VersionHandler))
+	if (cfg.ServerCrt != "" && cfg.ServerKey != "") || len(cfg.DomainNames) > 0 {
+		if srv.TLSConfig, err = TLSConfig(cfg, tls.NoClientCert); err != nil {
+			srv.Close()
+			return nil, err
+		}
+	}
+	return srv, nil
+}
+
+// HealthHandler reports that server is alive
+func HealthHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Addr returns listen address of the server
+func (s *Server) Addr() string {
+	return fmt.Sprintf(":%d", s.Config.Port)
+}
+
+// UseTLS reports if server should serve HTTPS
+func (s *Server) UseTLS() bool {
+	return s.TLSConfig != nil
+}
+
+// Run starts the server and serves requests until SIGTERM or SIGINT signal,
+// then it stops accepting new connections and waits for in-flight requests
+// up to drain timeout
+func (s *Server) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	ln, err := net.Listen("tcp", s.Addr())
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve serves requests on given listener until context is done and shuts
+// server down gracefully
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	defer s.Close()
+	server := &http.Server{
+		Handler:           s.Router,
+		TLSConfig:         s.TLSConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errc := make(chan error, 1)
+	go func() {
+		if s.UseTLS() {
+			log.Printf("starting HTTPs server on %s", ln.Addr())
+			// certificates are provided by TLS configuration
+			errc <- server.ServeTLS(ln, "", "")
+		} else {
+			log.Printf("starting HTTP server on %s", ln.Addr())
+			errc <- server.Serve(ln)
+		}
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	log.Printf("shutting down server, drain timeout %v", s.DrainTimeout)
+	sctx, cancel := context.WithTimeout(context.Background(), s.DrainTimeout)
+	defer cancel()
+	if err := server.Shutdown(sctx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Close releases resources of the server, e.g. its log file
+func (s *Server) Close() error {
+	if s.logWriter == nil {
+		return nil
+	}
+	err := s.logWriter.Close()
+	s.logWriter = nil
+	return err
+}
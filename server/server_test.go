@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OreCast/common/config"
+	"github.com/gin-gonic/gin"
+)
+
+// helper function to get response body of given URL
+func get(t *testing.T, rurl string) (int, string) {
+	resp, err := http.Get(rurl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+// TestServer
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "page.html"), []byte("static page"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.WebServer{
+		Base:          "/discovery",
+		StaticDir:     dir,
+		LogFile:       filepath.Join(dir, "server.log"),
+		LimiterPeriod: "10-M",
+	}
+	srv, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.DrainTimeout = 5 * time.Second
+	started := make(chan bool)
+	srv.Group.GET("/slow", func(c *gin.Context) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ctx, ln) }()
+	base := "http://" + ln.Addr().String() + "/discovery"
+
+	code, body := get(t, base+"/static/page.html")
+	if code != http.StatusOK || body != "static page" {
+		t.Errorf("wrong static response %d %q", code, body)
+	}
+	resp, err := http.Get(base + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("wrong health response %d %v", resp.StatusCode, resp.Header)
+	}
+
+	// in-flight request is completed on shutdown
+	slow := make(chan string, 1)
+	go func() {
+		_, body := get(t, base+"/slow")
+		slow <- body
+	}()
+	<-started
+	cancel()
+	if body := <-slow; body != "done" {
+		t.Errorf("in-flight request is not drained, response %q", body)
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("shutdown error %v", err)
+	}
+	data, err := os.ReadFile(cfg.LogFile)
+	if err != nil || !strings.Contains(string(data), "/discovery/healthz") {
+		t.Errorf("requests are not logged, error %v", err)
+	}
+}
+
+// TestLimiter
+func TestLimiter(t *testing.T) {
+	if _, _, err := ParseRate("100"); err == nil {
+		t.Error("invalid rate is accepted")
+	}
+	if limit, burst, err := ParseRate("120-M"); err != nil || burst != 120 || limit != 2 {
+		t.Errorf("wrong rate %v %d %v", limit, burst, err)
+	}
+	gin.SetMode(gin.TestMode)
+	limiter, err := LimiterMiddleware("2-H")
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := gin.New()
+	router.Use(limiter)
+	router.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	var codes []int
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		codes = append(codes, rec.Code)
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("wrong limiter responses %v", codes)
+	}
+}
+
+// TestTrustedProxies
+func TestTrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv, err := New(config.WebServer{LimiterPeriod: "1-H"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Group.GET("/data", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	// spoofed X-Forwarded-For headers do not bypass the limiter
+	var codes []int
+	for _, ip := range []string{"192.0.2.1", "192.0.2.2"} {
+		req := httptest.NewRequest("GET", "/data", nil)
+		req.Header.Set("X-Forwarded-For", ip)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		codes = append(codes, rec.Code)
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Errorf("wrong limiter responses of spoofed clients %v", codes)
+	}
+	if _, err := New(config.WebServer{TrustedProxies: []string{"proxy"}}); err == nil {
+		t.Error("invalid trusted proxy is accepted")
+	}
+}
+
+// TestLimiterEviction
+func TestLimiterEviction(t *testing.T) {
+	limit, burst, _ := ParseRate("2-H")
+	limiters := newIPLimiters(limit, burst)
+	if limiters.idle != time.Hour {
+		t.Errorf("limiter is evicted before it is replenished, idle %v", limiters.idle)
+	}
+	now := time.Now()
+	first := limiters.get("192.0.2.1", now)
+	limiters.get("192.0.2.2", now.Add(30*time.Minute))
+	if limiters.get("192.0.2.1", now.Add(time.Minute)) != first {
+		t.Error("limiter of active client is replaced")
+	}
+	limiters.get("192.0.2.3", now.Add(80*time.Minute))
+	if len(limiters.limiters) != 2 {
+		t.Errorf("idle limiters are not evicted, %d limiters", len(limiters.limiters))
+	}
+	if _, ok := limiters.limiters["192.0.2.1"]; ok {
+		t.Error("idle limiter is kept")
+	}
+}
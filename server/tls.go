@@ -0,0 +1,181 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/OreCast/common/config"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// AutocertDir defines directory where LetsEncrypt certificates are cached
+var AutocertDir = "certs"
+
+// CertCheckInterval defines how often certificate files are checked for changes
+var CertCheckInterval = 10 * time.Second
+
+// TLSConfig builds TLS configuration from web server parameters:
+//   - rootCAs file or directory of PEM files defines CA pool used to verify
+//     client certificates and outgoing connections
+//   - server_cert and server_key files are reloaded when they are changed
+//   - domain_names are served with LetsEncrypt certificates cached in
+//     AutocertDir directory
+//
+// Client certificates are verified according to given client auth type,
+// e.g. tls.RequireAndVerifyClientCert requires rootCAs parameter.
+func TLSConfig(cfg config.WebServer, clientAuth tls.ClientAuthType) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: clientAuth,
+	}
+	if cfg.RootCAs != "" {
+		pool, err := CertPool(cfg.RootCAs)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = pool
+		if tlsConfig.RootCAs, err = x509.SystemCertPool(); err != nil {
+			tlsConfig.RootCAs = x509.NewCertPool()
+		}
+		if err := appendCerts(tlsConfig.RootCAs, cfg.RootCAs); err != nil {
+			return nil, err
+		}
+	} else if clientAuth >= tls.VerifyClientCertIfGiven {
+		return nil, errors.New("client certificates verification requires rootCAs")
+	}
+	switch {
+	case cfg.ServerCrt != "" && cfg.ServerKey != "":
+		reloader, err := NewCertReloader(cfg.ServerCrt, cfg.ServerKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.GetCertificate = reloader.GetCertificate
+	case len(cfg.DomainNames) > 0:
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.DomainNames...),
+			Cache:      autocert.DirCache(AutocertDir),
+		}
+		tlsConfig.GetCertificate = manager.GetCertificate
+		tlsConfig.NextProtos = []string{"h2", "http/1.1", "acme-tls/1"}
+	default:
+		return nil, errors.New("neither server certificates nor domain names are provided")
+	}
+	return tlsConfig, nil
+}
+
+// CertPool returns pool of certificates of given PEM file or directory
+func CertPool(path string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if err := appendCerts(pool, path); err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// helper function to add certificates of given PEM file or directory to the pool
+func appendCerts(pool *x509.CertPool, path string) error {
+	files := []string{path}
+	if info, err := os.Stat(path); err != nil {
+		return err
+	} else if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		files = nil
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+	for _, fname := range files {
+		data, err := os.ReadFile(fname)
+		if err != nil {
+			return err
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			log.Printf("WARNING: no certificates found in %s", fname)
+		}
+	}
+	return nil
+}
+
+// CertReloader provides server certificate which is reloaded when its
+// files are changed, e.g. renewed by external tool
+type CertReloader struct {
+	CertFile string // server certificate file
+	KeyFile  string // server certificate key file
+
+	mutex   sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time // modification time of loaded files
+	checked time.Time // time of last check of files
+}
+
+// NewCertReloader loads certificate from given files
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{CertFile: certFile, KeyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// helper function to return latest modification time of certificate files
+func (r *CertReloader) lastModified() (time.Time, error) {
+	var last time.Time
+	for _, fname := range []string{r.CertFile, r.KeyFile} {
+		info, err := os.Stat(fname)
+		if err != nil {
+			return last, err
+		}
+		if info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+	}
+	return last, nil
+}
+
+// helper function to load certificate files
+func (r *CertReloader) reload() error {
+	modTime, err := r.lastModified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+	if err != nil {
+		return fmt.Errorf("unable to load server certificate, error %v", err)
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+// GetCertificate implements tls.Config GetCertificate function, it reloads
+// certificate if its files are changed, on reload errors previous
+// certificate is used
+func (r *CertReloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if time.Since(r.checked) < CertCheckInterval {
+		return r.cert, nil
+	}
+	r.checked = time.Now()
+	if modTime, err := r.lastModified(); err == nil && modTime.After(r.modTime) {
+		if err := r.reload(); err != nil {
+			log.Println("WARNING:", err)
+		} else {
+			log.Printf("server certificate %s is reloaded", r.CertFile)
+		}
+	}
+	return r.cert, nil
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/OreCast/common/config"
+)
+
+// helper function to write self-signed certificate and its key
+func writeCert(t *testing.T, certFile, keyFile, name string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kder, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cdata := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	kdata := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder})
+	if err := os.WriteFile(certFile, cdata, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, kdata, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// helper function to return common name of provided certificate
+func certName(t *testing.T, tlsConfig *tls.Config) string {
+	cert, err := tlsConfig.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+// TestTLSConfig
+func TestTLSConfig(t *testing.T) {
+	defer func(d time.Duration) { CertCheckInterval = d }(CertCheckInterval)
+	CertCheckInterval = 0
+	dir := t.TempDir()
+	cfg := config.WebServer{
+		ServerCrt: filepath.Join(dir, "server.crt"),
+		ServerKey: filepath.Join(dir, "server.key"),
+		RootCAs:   filepath.Join(dir, "cas"),
+	}
+	writeCert(t, cfg.ServerCrt, cfg.ServerKey, "first")
+	if _, err := TLSConfig(cfg, tls.RequireAndVerifyClientCert); err == nil {
+		t.Error("missing rootCAs are accepted")
+	}
+	if err := os.Mkdir(cfg.RootCAs, 0700); err != nil {
+		t.Fatal(err)
+	}
+	writeCert(t, filepath.Join(cfg.RootCAs, "ca.pem"), filepath.Join(dir, "ca.key"), "ca")
+	tlsConfig, err := TLSConfig(cfg, tls.RequireAndVerifyClientCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.ClientCAs == nil || tlsConfig.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Error("client certificates verification is not configured")
+	}
+	if name := certName(t, tlsConfig); name != "first" {
+		t.Errorf("wrong certificate %s", name)
+	}
+
+	// certificate is reloaded when its files are changed
+	writeCert(t, cfg.ServerCrt, cfg.ServerKey, "second")
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(cfg.ServerCrt, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if name := certName(t, tlsConfig); name != "second" {
+		t.Errorf("certificate is not reloaded, got %s", name)
+	}
+
+	// LetsEncrypt certificates for domain names
+	defer func(d string) { AutocertDir = d }(AutocertDir)
+	AutocertDir = filepath.Join(dir, "autocert")
+	tlsConfig, err = TLSConfig(config.WebServer{DomainNames: []string{"orecast.org"}}, tls.NoClientCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.GetCertificate == nil || tlsConfig.NextProtos[len(tlsConfig.NextProtos)-1] != "acme-tls/1" {
+		t.Errorf("autocert is not configured %+v", tlsConfig)
+	}
+	if _, err := TLSConfig(config.WebServer{}, tls.NoClientCert); err == nil {
+		t.Error("configuration without certificates is accepted")
+	}
+}
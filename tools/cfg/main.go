@@ -0,0 +1,301 @@
+package main
+
+// cfg tool loads OreCast configuration the same way as services do and
+// allows to inspect, validate and compare configurations, e.g.
+//
+//	cfg -config orecast.yaml show
+//	cfg -config orecast.yaml -format json show
+//	cfg -config orecast.yaml validate discovery authz
+//	cfg diff orecast.yaml orecast.production.yaml
+//	cfg sample > orecast.yaml
+//
+// Exit codes: 0 on success, 1 if configuration is invalid or configurations
+// differ, 2 on usage errors or when configuration can not be loaded.
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/OreCast/common/config"
+	"gopkg.in/yaml.v3"
+)
+
+// exit codes of the tool
+const (
+	exitOK      = 0
+	exitInvalid = 1
+	exitUsage   = 2
+)
+
+// options represents command line options of the tool
+type options struct {
+	File      string           // configuration file
+	Overlays  []string         // overlay files
+	Env       string           // environment overlay
+	Overrides config.Overrides // key path values set via command line
+	Format    string           // output format, yaml or json
+	Secrets   bool             // show secret values
+}
+
+// helper type to collect repeated string flags
+type listFlag []string
+
+// String implements flag.Value interface
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set implements flag.Value interface
+func (l *listFlag) Set(val string) error {
+	*l = append(*l, val)
+	return nil
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// helper function to run the tool with given arguments, it returns exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	opts := options{Overrides: make(config.Overrides)}
+	fs := flag.NewFlagSet("cfg", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.File, "config", "", "configuration file, $HOME/.orecast.yaml by default")
+	fs.Var((*listFlag)(&opts.Overlays), "overlay", "overlay configuration file, can be repeated")
+	fs.StringVar(&opts.Env, "env", "", "environment overlay, "+config.EnvVar+" by default")
+	fs.Var(opts.Overrides, "set", "override configuration value, e.g. -set discovery.port=8320")
+	fs.StringVar(&opts.Format, "format", "yaml", "output format, yaml or json")
+	fs.BoolVar(&opts.Secrets, "show-secrets", false, "do not redact secret values")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: cfg [options] <show|validate [sections]|diff <file1> <file2>|sample>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if opts.Format != "yaml" && opts.Format != "json" {
+		fmt.Fprintf(stderr, "ERROR: unsupported format %q\n", opts.Format)
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	cmd, rest := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "show":
+		return show(opts, stdout, stderr)
+	case "validate":
+		return validate(opts, rest, stdout, stderr)
+	case "diff":
+		if len(rest) != 2 {
+			fmt.Fprintln(stderr, "ERROR: diff requires two configuration files")
+			return exitUsage
+		}
+		return diff(opts, rest[0], rest[1], stdout, stderr)
+	case "sample":
+		if err := config.Sample(stdout); err != nil {
+			fmt.Fprintln(stderr, "ERROR:", err)
+			return exitUsage
+		}
+		return exitOK
+	}
+	fmt.Fprintf(stderr, "ERROR: unknown command %q\n", cmd)
+	fs.Usage()
+	return exitUsage
+}
+
+// helper function to load configuration, validation errors are returned
+// along with loaded configuration
+func load(opts options, cfile string, sections ...string) (config.OreCastConfig, error) {
+	return newLoader(opts, cfile, sections...).Parse()
+}
+
+// helper function to create loader of given configuration file
+func newLoader(opts options, cfile string, sections ...string) *config.Loader {
+	return &config.Loader{
+		File:      cfile,
+		Sections:  sections,
+		Overrides: opts.Overrides,
+		Overlays:  opts.Overlays,
+		Env:       opts.Env,
+	}
+}
+
+// helper function to report loading error, it returns exit code
+func report(err error, stderr io.Writer) int {
+	var verr config.ValidationError
+	if errors.As(err, &verr) {
+		fmt.Fprintln(stderr, verr.Error())
+		return exitInvalid
+	}
+	fmt.Fprintln(stderr, "ERROR:", err)
+	return exitUsage
+}
+
+// show prints effective configuration
+func show(opts options, stdout, stderr io.Writer) int {
+	cfg, err := load(opts, opts.File)
+	code := exitOK
+	if err != nil {
+		if code = report(err, stderr); code != exitInvalid {
+			return code
+		}
+	}
+	settings := cfg.Settings()
+	if !opts.Secrets {
+		config.Redact("", settings)
+	}
+	if err := write(stdout, opts.Format, settings); err != nil {
+		fmt.Fprintln(stderr, "ERROR:", err)
+		return exitUsage
+	}
+	return code
+}
+
+// validate validates configuration
+func validate(opts options, sections []string, stdout, stderr io.Writer) int {
+	for _, section := range sections {
+		found := false
+		for _, s := range config.Sections {
+			if strings.EqualFold(s, section) {
+				found = true
+			}
+		}
+		if !found {
+			fmt.Fprintf(stderr, "ERROR: unknown section %q\n", section)
+			return exitUsage
+		}
+	}
+	loader := newLoader(opts, opts.File, sections...)
+	cfg, err := loader.Parse()
+	if err == nil && len(sections) == 0 {
+		// sections declared by configuration files are validated by default
+		err = cfg.Validate(loader.Declared()...)
+	}
+	if err != nil {
+		return report(err, stderr)
+	}
+	fmt.Fprintln(stdout, "configuration is valid")
+	return exitOK
+}
+
+// diff prints differences of effective configurations, values are compared
+// before redaction and changed secrets are reported by redacted marker.
+// Sections declared by configuration files are validated.
+func diff(opts options, file1, file2 string, stdout, stderr io.Writer) int {
+	code := exitOK
+	var raws, shown []map[string]string
+	for _, fname := range []string{file1, file2} {
+		loader := newLoader(opts, fname)
+		cfg, err := loader.Parse()
+		if err == nil {
+			err = cfg.Validate(loader.Declared()...)
+		}
+		if err != nil {
+			var verr config.ValidationError
+			if !errors.As(err, &verr) {
+				fmt.Fprintf(stderr, "ERROR: %s: %v\n", fname, err)
+				return exitUsage
+			}
+			fmt.Fprintf(stderr, "%s: %s\n", fname, verr.Error())
+			code = exitInvalid
+		}
+		raw := make(map[string]string)
+		flatten("", cfg.Settings(), raw)
+		raws = append(raws, raw)
+		if opts.Secrets {
+			shown = append(shown, raw)
+			continue
+		}
+		settings := cfg.Settings()
+		config.Redact("", settings)
+		redacted := make(map[string]string)
+		flatten("", settings, redacted)
+		shown = append(shown, redacted)
+	}
+	lines := diffLines(raws[0], raws[1], shown[0], shown[1])
+	if len(lines) == 0 {
+		return code
+	}
+	fmt.Fprintf(stdout, "--- %s\n+++ %s\n", file1, file2)
+	for _, line := range lines {
+		fmt.Fprintln(stdout, line)
+	}
+	return exitInvalid
+}
+
+// helper function to produce sorted diff lines of flat settings, raw values
+// are compared and shown values are printed
+func diffLines(old, new, oldShown, newShown map[string]string) []string {
+	keys := make(map[string]bool)
+	for key := range old {
+		keys[key] = true
+	}
+	for key := range new {
+		keys[key] = true
+	}
+	var sorted []string
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	var out []string
+	for _, key := range sorted {
+		oval, ook := old[key]
+		nval, nok := new[key]
+		if ook && nok && oval == nval {
+			continue
+		}
+		// redacted secrets look the same although their values differ
+		if ook && nok && oldShown[key] == newShown[key] {
+			out = append(out, fmt.Sprintf("~ %s: %s (changed)", key, newShown[key]))
+			continue
+		}
+		if ook {
+			out = append(out, fmt.Sprintf("- %s: %s", key, oldShown[key]))
+		}
+		if nok {
+			out = append(out, fmt.Sprintf("+ %s: %s", key, newShown[key]))
+		}
+	}
+	return out
+}
+
+// helper function to flatten settings into key paths with string values
+func flatten(prefix string, val interface{}, out map[string]string) {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			flatten(key, item, out)
+		}
+	case []interface{}:
+		for i, item := range v {
+			flatten(fmt.Sprintf("%s[%d]", prefix, i), item, out)
+		}
+	default:
+		out[prefix] = fmt.Sprintf("%v", v)
+	}
+}
+
+// helper function to write settings in given format
+func write(w io.Writer, format string, settings map[string]interface{}) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(settings)
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	defer enc.Close()
+	return enc.Encode(settings)
+}